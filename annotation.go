@@ -0,0 +1,454 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	strategyDataSource  = "data_source"
+	strategyRemoteState = "remote_state"
+
+	audiencePublic   = "public"
+	audienceInternal = "internal"
+)
+
+// Annotation holds the audiences and arguments of the visibility tags on an output, for example
+// @public(name="vpc_id", description="The VPC", group="network", strategy="remote_state", since="1.2.0").
+type Annotation struct {
+	// Audiences are the interface modules the output is exported in: public, internal or the name of a team.
+	Audiences []string
+	// Name is the name the output is exported as. It defaults to the name of the producer's output.
+	Name        string
+	Description string
+	Group       string
+	// Strategy is how the interface looks the value up: with a data source (the default) or from the remote state.
+	Strategy string
+	Since    string
+	// Stability is stable, experimental or deprecated, from @stable, @experimental or @deprecated("message").
+	Stability   string
+	Deprecation string
+}
+
+var (
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+	versionPattern    = regexp.MustCompile(`^v?\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`)
+)
+
+// annotationArguments validates and stores each key="value" argument of a visibility tag.
+var annotationArguments = map[string]func(annotation *Annotation, value string) error{
+	"name": func(annotation *Annotation, value string) error {
+		if !identifierPattern.MatchString(value) {
+			return fmt.Errorf("name %q is not a valid Terraform identifier", value)
+		}
+		annotation.Name = value
+		return nil
+	},
+	"description": func(annotation *Annotation, value string) error {
+		annotation.Description = value
+		return nil
+	},
+	"group": func(annotation *Annotation, value string) error {
+		if !identifierPattern.MatchString(value) {
+			return fmt.Errorf("group %q is not a valid Terraform identifier", value)
+		}
+		annotation.Group = value
+		return nil
+	},
+	"strategy": func(annotation *Annotation, value string) error {
+		if value != strategyDataSource && value != strategyRemoteState {
+			return fmt.Errorf("strategy %q is not one of %s, %s", value, strategyDataSource, strategyRemoteState)
+		}
+		annotation.Strategy = value
+		return nil
+	},
+	"since": func(annotation *Annotation, value string) error {
+		if !versionPattern.MatchString(value) {
+			return fmt.Errorf("since %q is not a semantic version such as 1.2.0", value)
+		}
+		annotation.Since = value
+		return nil
+	},
+}
+
+// AnnotationError is an invalid annotation, reported with the file and line it is on.
+type AnnotationError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (e *AnnotationError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+}
+
+// parseAnnotation parses the visibility tags in a comment line and reports false if the line has none. @public exports
+// an output to everyone, @internal to the owning organisation and @shared(team-a, team-b) to the teams listed. A tag
+// may be followed by a parenthesised list of key="value" arguments, or by key=value arguments without parentheses, as
+// in @public group=network. Tags are only recognised at the start of the
+// comment text, one after the other, so that an address such as admin@internal.corp in an ordinary comment is not an
+// annotation; anything after the last tag is ignored.
+func parseAnnotation(comment string) (Annotation, bool, error) {
+	var annotation Annotation
+	text := strings.TrimLeft(strings.TrimLeft(comment, "#/"), " \t")
+	tag, rest := leadingTag(text)
+	// "@shared drive" is an ordinary comment: @shared is only a tag with the teams it is shared with.
+	if tag == "shared" && !strings.HasPrefix(strings.TrimLeft(rest, " \t"), "(") && strings.TrimSpace(rest) != "" {
+		return annotation, false, nil
+	}
+	found := false
+	for tag != "" {
+		found = true
+		parse := parseTag
+		if tag == stabilityStable || tag == stabilityExperimental || tag == stabilityDeprecated {
+			parse = parseStabilityTag
+		}
+		parsed, remaining, err := parse(tag, rest)
+		if err != nil {
+			return annotation, true, err
+		}
+		if err := annotation.merge(parsed); err != nil {
+			return annotation, true, err
+		}
+		tag, rest = leadingTag(strings.TrimLeft(remaining, " \t"))
+	}
+	return annotation, found, nil
+}
+
+// leadingTag returns the visibility or stability tag that s starts with, and the text that follows it.
+func leadingTag(s string) (string, string) {
+	if !strings.HasPrefix(s, "@") {
+		return "", ""
+	}
+	s = s[1:]
+	for _, tag := range []string{audiencePublic, audienceInternal, "shared", stabilityStable, stabilityExperimental, stabilityDeprecated} {
+		if strings.HasPrefix(s, tag) && (len(s) == len(tag) || !isIdentifierByte(s[len(tag)])) {
+			return tag, s[len(tag):]
+		}
+	}
+	return "", ""
+}
+
+// parseTag parses the arguments that follow a tag and returns the text after them.
+func parseTag(tag string, rest string) (Annotation, string, error) {
+	annotation := Annotation{}
+	if tag != "shared" {
+		annotation.Audiences = []string{tag}
+	}
+	seen := make(map[string]bool)
+	trimmed := strings.TrimLeft(rest, " \t")
+	if !strings.HasPrefix(trimmed, "(") {
+		if tag == "shared" {
+			return annotation, rest, fmt.Errorf("@shared needs the teams it is shared with, e.g. @shared(team-a, team-b)")
+		}
+		return parseBareArguments(tag, annotation, rest, seen)
+	}
+	parser := argumentParser{tag: tag, input: trimmed, position: 1}
+	for {
+		parser.skipSpaces()
+		if parser.consume(')') {
+			break
+		}
+		key, err := parser.identifier()
+		if err != nil {
+			return annotation, "", err
+		}
+		parser.skipSpaces()
+		if parser.peek(',') || parser.peek(')') {
+			if tag != "shared" {
+				return annotation, "", fmt.Errorf("@%s takes no team names, only key=\"value\" arguments", tag)
+			}
+			if key == audiencePublic || key == audienceInternal {
+				return annotation, "", fmt.Errorf("%q cannot be used as a team name, use @%s instead", key, key)
+			}
+			annotation.Audiences = appendUnique(annotation.Audiences, key)
+		} else {
+			if !parser.consume('=') {
+				return annotation, "", parser.errorf("expected \"=\" after %s", key)
+			}
+			parser.skipSpaces()
+			value, err := parser.stringLiteral()
+			if err != nil {
+				return annotation, "", err
+			}
+			if err := setAnnotationArgument(&annotation, tag, key, value, seen); err != nil {
+				return annotation, "", err
+			}
+			parser.skipSpaces()
+		}
+		if parser.consume(')') {
+			break
+		}
+		if !parser.consume(',') {
+			return annotation, "", parser.errorf("expected \",\" or \")\" after %s", key)
+		}
+	}
+	if tag == "shared" && len(annotation.Audiences) == 0 {
+		return annotation, "", fmt.Errorf("@shared needs the teams it is shared with, e.g. @shared(team-a, team-b)")
+	}
+	return parseBareArguments(tag, annotation, parser.input[parser.position:], seen)
+}
+
+// parseBareArguments parses the key=value arguments that may follow a tag without parentheses, as in
+// @public group=network, and returns the text after them. A value is either double-quoted or runs up to the next space.
+func parseBareArguments(tag string, annotation Annotation, rest string, seen map[string]bool) (Annotation, string, error) {
+	parser := argumentParser{tag: tag, input: rest}
+	for {
+		parser.skipSpaces()
+		start := parser.position
+		key, err := parser.identifier()
+		if err == nil {
+			parser.skipSpaces()
+		}
+		if err != nil || !parser.consume('=') {
+			return annotation, rest[start:], nil
+		}
+		parser.skipSpaces()
+		var value string
+		if parser.peek('"') {
+			if value, err = parser.stringLiteral(); err != nil {
+				return annotation, "", err
+			}
+		} else if value = parser.bareValue(); value == "" {
+			return annotation, "", parser.errorf("expected a value after %s=", key)
+		}
+		if err := setAnnotationArgument(&annotation, tag, key, value, seen); err != nil {
+			return annotation, "", err
+		}
+	}
+}
+
+// setAnnotationArgument validates and stores a key="value" argument of a tag. seen holds the keys the tag already has.
+func setAnnotationArgument(annotation *Annotation, tag string, key string, value string, seen map[string]bool) error {
+	setArgument, known := annotationArguments[key]
+	if !known {
+		return fmt.Errorf("unknown @%s argument %q, expected one of %s", tag, key, strings.Join(annotationArgumentNames(), ", "))
+	}
+	if seen[key] {
+		return fmt.Errorf("duplicate @%s argument %q", tag, key)
+	}
+	seen[key] = true
+	if err := setArgument(annotation, value); err != nil {
+		return fmt.Errorf("invalid @%s argument: %v", tag, err)
+	}
+	return nil
+}
+
+// merge adds the audiences and arguments of another tag on the same output. An argument given with different values
+// by two tags is an error.
+func (a *Annotation) merge(other Annotation) error {
+	for _, audience := range other.Audiences {
+		a.Audiences = appendUnique(a.Audiences, audience)
+	}
+	fields := []struct {
+		name   string
+		target *string
+		value  string
+	}{
+		{"name", &a.Name, other.Name},
+		{"description", &a.Description, other.Description},
+		{"group", &a.Group, other.Group},
+		{"strategy", &a.Strategy, other.Strategy},
+		{"since", &a.Since, other.Since},
+		{"stability", &a.Stability, other.Stability},
+		{"deprecation message", &a.Deprecation, other.Deprecation},
+	}
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		if *field.target != "" && *field.target != field.value {
+			return fmt.Errorf("conflicting values for %s: %q and %q", field.name, *field.target, field.value)
+		}
+		*field.target = field.value
+	}
+	return nil
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func annotationArgumentNames() []string {
+	var names []string
+	for name := range annotationArguments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+type argumentParser struct {
+	tag      string
+	input    string
+	position int
+}
+
+func (p *argumentParser) errorf(format string, args ...interface{}) error {
+	if p.position >= len(p.input) {
+		return fmt.Errorf("invalid @"+p.tag+" arguments: "+format+", found the end of the line", args...)
+	}
+	return fmt.Errorf("invalid @"+p.tag+" arguments: "+format+", found %q", append(args, p.input[p.position:])...)
+}
+
+func (p *argumentParser) peek(c byte) bool {
+	return p.position < len(p.input) && p.input[p.position] == c
+}
+
+func (p *argumentParser) skipSpaces() {
+	for p.position < len(p.input) && (p.input[p.position] == ' ' || p.input[p.position] == '\t') {
+		p.position++
+	}
+}
+
+func (p *argumentParser) consume(c byte) bool {
+	if p.position < len(p.input) && p.input[p.position] == c {
+		p.position++
+		return true
+	}
+	return false
+}
+
+func (p *argumentParser) identifier() (string, error) {
+	start := p.position
+	for p.position < len(p.input) && isIdentifierByte(p.input[p.position]) {
+		p.position++
+	}
+	if start == p.position {
+		return "", p.errorf("expected an argument name")
+	}
+	return p.input[start:p.position], nil
+}
+
+// bareValue reads an unquoted value, which runs up to the next space.
+func (p *argumentParser) bareValue() string {
+	start := p.position
+	for p.position < len(p.input) && p.input[p.position] != ' ' && p.input[p.position] != '\t' {
+		p.position++
+	}
+	return p.input[start:p.position]
+}
+
+// stringLiteral reads a double-quoted string in which \" and \\ are the only escapes.
+func (p *argumentParser) stringLiteral() (string, error) {
+	if !p.consume('"') {
+		return "", p.errorf("expected a double-quoted value")
+	}
+	var value strings.Builder
+	for p.position < len(p.input) {
+		c := p.input[p.position]
+		p.position++
+		switch c {
+		case '"':
+			return value.String(), nil
+		case '\\':
+			if p.position < len(p.input) && (p.input[p.position] == '"' || p.input[p.position] == '\\') {
+				value.WriteByte(p.input[p.position])
+				p.position++
+				continue
+			}
+			p.position--
+			return "", p.errorf("unsupported escape in value")
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("invalid @%s arguments: unterminated string", p.tag)
+}
+
+// ExportedName is the name the interface module exports the output as.
+func (o AnnotatedOutput) ExportedName() string {
+	if o.Annotation.Name != "" {
+		return o.Annotation.Name
+	}
+	return o.Output
+}
+
+// displayName is the output's name, followed by the name it is exported as when it is renamed.
+func displayName(output AnnotatedOutput) string {
+	if output.ExportedName() != output.Output {
+		return fmt.Sprintf("%s (as %s)", output.Output, output.ExportedName())
+	}
+	return output.Output
+}
+
+// checkExportedNames fails if two outputs would be exported with the same name to the same audience.
+func checkExportedNames(outputs []AnnotatedOutput) error {
+	exported := make(map[string]AnnotatedOutput)
+	for _, output := range outputs {
+		for _, audience := range output.Annotation.Audiences {
+			key := audience + "/" + output.ExportedName()
+			if previous, exists := exported[key]; exists {
+				return &AnnotationError{File: output.File, Line: output.Line, Message: fmt.Sprintf(
+					"output %q is exported to %s as %q, which %s:%d already exports", output.Output, audience, output.ExportedName(), previous.File, previous.Line)}
+			}
+			exported[key] = output
+		}
+	}
+	return nil
+}
+
+// hasAnnotationTag reports whether a comment line in content starts with a tag, without validating it.
+func hasAnnotationTag(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "//") {
+			continue
+		}
+		if _, found, _ := parseAnnotation(line); found {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedAudiences returns the audiences of outputs with public first, then internal, then the teams by name.
+func sortedAudiences(outputs []AnnotatedOutput) []string {
+	seen := make(map[string]bool)
+	var teams []string
+	for _, output := range outputs {
+		for _, audience := range output.Annotation.Audiences {
+			if !seen[audience] && audience != audiencePublic && audience != audienceInternal {
+				teams = append(teams, audience)
+			}
+			seen[audience] = true
+		}
+	}
+	sort.Strings(teams)
+	var audiences []string
+	for _, audience := range []string{audiencePublic, audienceInternal} {
+		if seen[audience] {
+			audiences = append(audiences, audience)
+		}
+	}
+	return append(audiences, teams...)
+}
+
+// hasAudience reports whether an output is exported to audience.
+func hasAudience(output AnnotatedOutput, audience string) bool {
+	for _, outputAudience := range output.Annotation.Audiences {
+		if outputAudience == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// hclString quotes a string for HCL, escaping template sequences as well.
+func hclString(s string) string {
+	quoted := strconv.Quote(s)
+	quoted = strings.ReplaceAll(quoted, "${", "$${")
+	return strings.ReplaceAll(quoted, "%{", "%%{")
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAnnotation(t *testing.T) {
+	annotation, found, err := parseAnnotation(`# @public`)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, Annotation{Audiences: []string{"public"}}, annotation)
+
+	annotation, found, err = parseAnnotation(`# @public(name="vpc_id", description="The \"main\" VPC, in C:\\", group="network", strategy="remote_state", since="1.2.0") more words`)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, Annotation{Audiences: []string{"public"}, Name: "vpc_id", Description: `The "main" VPC, in C:\`, Group: "network", Strategy: strategyRemoteState, Since: "1.2.0"}, annotation)
+
+	_, found, _ = parseAnnotation(`# @publicity is not an annotation`)
+	assert.False(t, found)
+
+	for _, comment := range []string{
+		`# @shared drive`,
+		`# mail admin@internal.corp`,
+		`// ask in #platform, not @public(name="x")`,
+		`# TODO: @internal only`,
+	} {
+		_, found, err := parseAnnotation(comment)
+		assert.Nil(t, err, comment)
+		assert.False(t, found, comment)
+	}
+
+	annotation, found, err = parseAnnotation(`# @public group=network`)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, Annotation{Audiences: []string{"public"}, Group: "network"}, annotation)
+
+	annotation, found, err = parseAnnotation(`# @shared(team-a) group = network description="The VPC" since=1.2.0 @stable and more words`)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, Annotation{Audiences: []string{"team-a"}, Group: "network", Description: "The VPC", Since: "1.2.0", Stability: stabilityStable}, annotation)
+
+	annotation, found, err = parseAnnotation(`// @internal @stable`)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, Annotation{Audiences: []string{"internal"}, Stability: stabilityStable}, annotation)
+
+	annotation, found, err = parseAnnotation(`# @internal @shared(team-a, team_b, name="vpc") @shared(team-a)`)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, Annotation{Audiences: []string{"internal", "team-a", "team_b"}, Name: "vpc"}, annotation)
+
+	for comment, message := range map[string]string{
+		`# @public(nmae="x")`:                     `unknown @public argument "nmae", expected one of description, group, name, since, strategy`,
+		`# @public(name="x", name="y")`:           `duplicate @public argument "name"`,
+		`# @public(name="vpc id")`:                `name "vpc id" is not a valid Terraform identifier`,
+		`# @public(strategy="magic")`:             `strategy "magic" is not one of data_source, remote_state`,
+		`# @public(since="next")`:                 `since "next" is not a semantic version`,
+		`# @public(name "x")`:                     `expected "=" after name, found "\"x\")"`,
+		`# @public(name=x)`:                       `expected a double-quoted value, found "x)"`,
+		`# @public(name="x"`:                      `expected "," or ")" after name, found the end of the line`,
+		`# @public(description="abc`:              `unterminated string`,
+		`# @public(description="a\nb")`:           `unsupported escape in value`,
+		`# @public(name="x" group="y")`:           `expected "," or ")" after name`,
+		`# @shared`:                               `@shared needs the teams it is shared with`,
+		`# @shared()`:                             `@shared needs the teams it is shared with`,
+		`# @internal(team-a)`:                     `@internal takes no team names`,
+		`# @shared(public)`:                       `"public" cannot be used as a team name, use @public instead`,
+		`# @public(name="a") @internal(name="b")`: `conflicting values for name: "a" and "b"`,
+		`# @public grop=network`:                  `unknown @public argument "grop"`,
+		`# @public group=net.work`:                `group "net.work" is not a valid Terraform identifier`,
+		`# @public group=`:                        `expected a value after group=, found the end of the line`,
+		`# @public(group="a") group=b`:            `duplicate @public argument "group"`,
+	} {
+		_, _, err := parseAnnotation(comment)
+		assert.ErrorContains(t, err, message, comment)
+	}
+}
+
+func TestProjectOutputsBareGroupArgument(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/project/network.tf", []byte(`# @public group=network
+output "vpc_id" {
+  value = aws_vpc.main.id
+}
+`), 0644)
+	outputs, err := projectOutputs(fs, "/project", false)
+	assert.Nil(t, err)
+	if assert.Len(t, outputs, 1) {
+		assert.Equal(t, "network", outputs[0].Annotation.Group)
+	}
+}
+
+func TestProjectOutputsIgnoreOrdinaryComments(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/project/main.tf", []byte(`# Lives on the @shared drive, mail admin@internal.corp
+output "vpc_id" {
+  value = aws_vpc.main.id
+}
+
+# @shared drive
+output "subnet_id" {
+  value = aws_subnet.main.id
+}
+`), 0644)
+	outputs, err := projectOutputs(fs, "/project", false)
+	assert.Nil(t, err)
+	assert.Empty(t, outputs)
+	assert.False(t, hasAnnotationTag("# mail admin@internal.corp\n"))
+	assert.True(t, hasAnnotationTag("locals {}\n  # @internal\n"))
+}
+
+func TestProjectOutputsAnnotations(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/project/network.tf", []byte(`# @public(name="network_vpc_id", description="The VPC", group="network", since="1.2.0")
+output "vpc_id" {
+  value = aws_vpc.main.id
+}
+`), 0644)
+	outputs, err := projectOutputs(fs, "/project", false)
+	assert.Nil(t, err)
+	assert.Equal(t, "network_vpc_id", outputs[0].ExportedName())
+	assert.Equal(t, "network", outputs[0].Annotation.Group)
+	assert.Equal(t, `# Available since 1.2.0
+output "network_vpc_id" {
+  description = "The VPC"
+  value = data.aws_vpc.main.id
+}
+
+`, renderOutputsFile(outputs))
+
+	afero.WriteFile(fs, "/project/dns.tf", []byte(`# @public(name="network_vpc_id")
+output "zone_id" {
+  value = aws_route53_zone.main.zone_id
+}
+`), 0644)
+	_, err = projectOutputs(fs, "/project", false)
+	assert.EqualError(t, err, `network.tf:2: output "vpc_id" is exported to public as "network_vpc_id", which dns.tf:2 already exports`)
+
+	afero.WriteFile(fs, "/project/dns.tf", []byte("# Zone\n# @public(strategy=\"remote\")\noutput \"zone_id\" {}\n"), 0644)
+	_, err = projectOutputs(fs, "/project", false)
+	assert.EqualError(t, err, `dns.tf:2: invalid @public argument: strategy "remote" is not one of data_source, remote_state`)
+}
+
+func TestPlanRemoteStateStrategy(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	state, err := os.ReadFile("testdata/fixtures/examples/simple/show.json")
+	assert.Nil(t, err)
+	schema, err := os.ReadFile("testdata/fixtures/examples/simple/providers_schema.json")
+	assert.Nil(t, err)
+	afero.WriteFile(fs, "/fixtures/show.json", state, 0644)
+	afero.WriteFile(fs, "/fixtures/providers_schema.json", schema, 0644)
+	afero.WriteFile(fs, "/project/main.tf", []byte(`terraform {
+  backend "s3" {
+    bucket  = "state"
+    key     = "network.tfstate"
+    encrypt = true
+  }
+}
+
+# @public(strategy="remote_state", name="pet")
+output "random_pet_name" {
+  value = random_pet.my_random_pet.id
+}
+`), 0644)
+	runner := FixtureRunner{Fs: fs, Dir: "/fixtures", BaseDir: "/project"}
+
+	plan, err := planProject(context.Background(), runner, fs, ProjectConfig{Path: "."}, "/project", ProjectSettings{Command: "terraform", Workspace: "prod"}, false)
+	assert.Nil(t, err)
+	assert.Empty(t, plan.Skipped)
+	assert.Equal(t, `data "terraform_remote_state" "this" {
+  backend = "s3"
+  workspace = "prod"
+  config = {
+    bucket = "state"
+    key = "network.tfstate"
+    encrypt = true
+  }
+}
+
+`, plan.Files[0].Content)
+	assert.Equal(t, `output "pet" {
+  value = data.terraform_remote_state.this.outputs.random_pet_name
+}
+
+`, plan.Files[2].Content)
+
+	afero.WriteFile(fs, "/project/main.tf", []byte("# @public(strategy=\"remote_state\")\noutput \"random_pet_name\" {\n  value = random_pet.my_random_pet.id\n}\n"), 0644)
+	plan, err = planProject(context.Background(), runner, fs, ProjectConfig{Path: "."}, "/project", ProjectSettings{Command: "terraform"}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "strategy remote_state needs a backend block in the project", plan.Skipped[0].Reason)
+	assert.Empty(t, plan.Files)
+}
+
+func TestFindBackendRejectsWhatRemoteStateCannotRead(t *testing.T) {
+	tests := map[string]string{
+		"backend \"s3\" {}\n":                                                               `backend "s3" in /project/main.tf has no arguments; a backend configured with -backend-config cannot be read with terraform_remote_state`,
+		"backend \"s3\" {\n  bucket = \"state\"\n}\n":                                       `backend "s3" in /project/main.tf has no key argument; a backend configured with -backend-config cannot be read with terraform_remote_state`,
+		"backend \"s3\" {\n  bucket = \"state\"\n  key = var.key\n}\n":                      `argument key of backend "s3" in /project/main.tf is not a literal value: var.key`,
+		"backend \"gcs\" {\n  bucket = \"state\"\n  prefix = \"${local.env}/network\"\n}\n": `argument prefix of backend "gcs" in /project/main.tf is not a literal value: "${local.env}/network"`,
+		"backend \"s3\" {\n  bucket = \"state\"\n  key = \"network.tfstate\"\n  assume_role {\n    role_arn = \"arn\"\n  }\n}\n": `backend "s3" in /project/main.tf has a nested assume_role block, which terraform_remote_state cannot be configured with`,
+		"backend \"remote\" {\n  organization = \"acme\"\n  workspaces {\n    prefix = \"network-\"\n  }\n}\n":                   `backend "remote" in /project/main.tf has a nested workspaces block, which terraform_remote_state cannot be configured with`,
+	}
+	for content, expected := range tests {
+		fs := afero.NewMemMapFs()
+		afero.WriteFile(fs, "/project/main.tf", []byte("terraform {\n"+content+"}\n"), 0644)
+		_, err := findBackend(fs, "/project")
+		assert.EqualError(t, err, expected, content)
+	}
+
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/project/main.tf", []byte("terraform {\n  backend \"local\" {}\n}\n"), 0644)
+	backend, err := findBackend(fs, "/project")
+	assert.Nil(t, err)
+	assert.Equal(t, &Backend{Type: "local"}, backend)
+}
+
+func TestPlanAudiences(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	state, err := os.ReadFile("testdata/fixtures/examples/simple/show.json")
+	assert.Nil(t, err)
+	schema, err := os.ReadFile("testdata/fixtures/examples/simple/providers_schema.json")
+	assert.Nil(t, err)
+	afero.WriteFile(fs, "/fixtures/show.json", state, 0644)
+	afero.WriteFile(fs, "/fixtures/providers_schema.json", schema, 0644)
+	afero.WriteFile(fs, "/project/main.tf", []byte(`# @public
+output "local_file_path" {
+  value = local_file.my_local_file.filename
+}
+
+# @internal
+# @shared(team-a)
+output "local_file_contents" {
+  value = local_file.my_local_file.content
+}
+
+# @shared(team-a, team-b, name="path")
+output "local_file_id" {
+  value = local_file.my_local_file.id
+}
+`), 0644)
+	runner := FixtureRunner{Fs: fs, Dir: "/fixtures", BaseDir: "/project"}
+	project := ProjectConfig{Path: ".", AudienceFolders: map[string]string{"team-b": "partners"}}
+
+	plan, err := planProject(context.Background(), runner, fs, project, "/project", ProjectSettings{Command: "terraform"}, false)
+	assert.Nil(t, err)
+	var audiences, dirs []string
+	for _, module := range plan.Interfaces {
+		audiences = append(audiences, module.Audience)
+		dirs = append(dirs, module.Dir)
+	}
+	assert.Equal(t, []string{"public", "internal", "team-a", "team-b"}, audiences)
+	assert.Equal(t, []string{"/project/interface", "/project/interface-internal", "/project/interface-team-a", "/project/partners"}, dirs)
+	assert.Len(t, plan.Files, 20)
+	assert.Equal(t, "/project/interface-team-a/generated_outputs.tf", plan.Files[12].Path)
+	assert.Equal(t, `output "local_file_contents" {
+  value = data.local_file.my_local_file.content
+}
+
+output "path" {
+  value = data.local_file.my_local_file.id
+}
+
+`, plan.Files[12].Content)
+
+	assert.Nil(t, writePlan(fs, plan, false))
+	exists, _ := afero.Exists(fs, "/project/partners/generated_data.tf")
+	assert.True(t, exists)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// Catalog is the index of the interface modules of every configured project, built from their manifests.
+type Catalog struct {
+	Interfaces []CatalogEntry `json:"interfaces"`
+}
+
+type CatalogEntry struct {
+	// Dir is the interface module's directory, relative to the directory project paths are relative to.
+	Dir string `json:"dir"`
+	Manifest
+}
+
+// buildCatalog reads the manifest of each interface module that the annotations of the projects call for. An
+// interface that was never generated is reported as a warning.
+func buildCatalog(fs afero.Fs, currentDir string, projects []ProjectConfig) (Catalog, []string, error) {
+	catalog := Catalog{Interfaces: []CatalogEntry{}}
+	var warnings []string
+	for _, project := range projects {
+		outputs, err := projectOutputs(fs, filepath.Join(currentDir, project.Path), false)
+		if err != nil {
+			return catalog, nil, fmt.Errorf("failed to read project %s: %v", project.Path, err)
+		}
+		for _, audience := range sortedAudiences(outputs) {
+			dir := interfaceDirectory(currentDir, project, audience)
+			path := filepath.Join(dir, manifestFileName)
+			content, found, err := fsReader(fs)(path)
+			if err != nil {
+				return catalog, nil, err
+			}
+			if !found {
+				warnings = append(warnings, fmt.Sprintf("%s has no manifest, run 'tf-interfaces generate' for project %s", dir, project.Path))
+				continue
+			}
+			entry := CatalogEntry{Dir: relativeTo(currentDir, dir)}
+			if err := json.Unmarshal([]byte(content), &entry.Manifest); err != nil {
+				return catalog, nil, fmt.Errorf("invalid manifest %s: %v", path, err)
+			}
+			if project.Owner != "" {
+				entry.Owner = project.Owner
+			}
+			catalog.Interfaces = append(catalog.Interfaces, entry)
+		}
+	}
+	return catalog, warnings, nil
+}
+
+// backingResource is the resource in the producer project that an output's value comes from.
+func backingResource(output ManifestOutput) string {
+	if output.Strategy == strategyRemoteState {
+		return "output " + output.Output + " in the producer's state"
+	}
+	parts := strings.Split(output.Reference, ".")
+	if len(parts) != 3 {
+		return output.Reference
+	}
+	return fmt.Sprintf("%s.%s (attribute %s)", parts[0], parts[1], parts[2])
+}
+
+func renderCatalogJSON(catalog Catalog) ([]byte, error) {
+	content, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
+
+func renderCatalogHTML(catalog Catalog) (string, error) {
+	var b strings.Builder
+	if err := catalogTemplate.Execute(&b, catalog); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+var catalogTemplate = template.Must(template.New("catalog").Funcs(template.FuncMap{
+	"resource": backingResource,
+	"type":     typeExpression,
+	"search": func(values ...string) string {
+		return strings.ToLower(strings.Join(values, " "))
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Interface catalog</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+input { font-size: 1em; padding: 0.4em; width: 30em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+.deprecated { text-decoration: line-through; }
+.meta { color: #555; }
+</style>
+</head>
+<body>
+<h1>Interface catalog</h1>
+<p><input id="search" type="search" placeholder="Search projects, owners, outputs and resources" autofocus></p>
+<p class="meta">{{len .Interfaces}} interface module(s). Also available as <a href="index.json">index.json</a>.</p>
+{{range .Interfaces}}{{$interface := .}}
+<section data-search="{{search .Project .Owner .Audience .Dir}}">
+<h2>{{.Project}} <small>({{.Audience}})</small></h2>
+<p class="meta">Owner: {{if .Owner}}{{.Owner}}{{else}}unknown{{end}} &middot; Module: <code>{{.Dir}}</code>{{if .ProducerCommit}} &middot; Commit: <code>{{.ProducerCommit}}</code>{{end}}</p>
+<table>
+<tr><th>Output</th><th>Description</th><th>Type</th><th>Stability</th><th>Sensitive</th><th>Backed by</th><th>Declared at</th></tr>
+{{range .Outputs}}<tr data-search="{{search .Name .Output .Description .Reference .Stability .Group}}"{{if eq .Stability "deprecated"}} class="deprecated"{{end}}>
+<td><code>{{.Name}}</code></td>
+<td>{{.Description}}</td>
+<td><code>{{type .Type}}</code></td>
+<td>{{.Stability}}{{if .Deprecation}}: {{.Deprecation}}{{end}}</td>
+<td>{{if .Sensitive}}yes{{else}}no{{end}}</td>
+<td><code>{{resource .}}</code></td>
+<td>{{$interface.Project}}/{{.File}}:{{.Line}}</td>
+</tr>
+{{end}}</table>
+</section>
+{{end}}
+<script>
+document.getElementById("search").addEventListener("input", function (event) {
+  var query = event.target.value.toLowerCase();
+  document.querySelectorAll("section").forEach(function (section) {
+    var sectionMatches = section.dataset.search.indexOf(query) >= 0;
+    var visible = 0;
+    section.querySelectorAll("tr[data-search]").forEach(function (row) {
+      var matches = sectionMatches || row.dataset.search.indexOf(query) >= 0;
+      row.style.display = matches ? "" : "none";
+      if (matches) visible++;
+    });
+    section.style.display = visible > 0 ? "" : "none";
+  });
+});
+</script>
+</body>
+</html>
+`))
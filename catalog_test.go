@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildCatalog(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/repo/network/main.tf", []byte(`# @public
+output "vpc_id" {
+  value = aws_vpc.main.id
+}
+
+# @internal
+output "zone_id" {
+  value = aws_route53_zone.main.zone_id
+}
+`), 0644)
+	manifest := Manifest{FormatVersion: 1, Project: "network", Audience: audiencePublic, Owner: "old-owner", Outputs: []ManifestOutput{
+		{Name: "vpc_id", Output: "vpc_id", File: "main.tf", Line: 2, Reference: "aws_vpc.main.id", Description: "<b>VPC</b>", Strategy: strategyDataSource, Stability: stabilityDeprecated},
+	}}
+	content, _ := json.Marshal(manifest)
+	afero.WriteFile(fs, "/repo/network/interface/generated_manifest.json", content, 0644)
+
+	catalog, warnings, err := buildCatalog(fs, "/repo", []ProjectConfig{{Path: "network", Owner: "platform"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/repo/network/interface-internal has no manifest, run 'tf-interfaces generate' for project network"}, warnings)
+	assert.Len(t, catalog.Interfaces, 1)
+	assert.Equal(t, "network/interface", catalog.Interfaces[0].Dir)
+	assert.Equal(t, "platform", catalog.Interfaces[0].Owner)
+
+	index, err := renderCatalogJSON(catalog)
+	assert.Nil(t, err)
+	assert.Contains(t, string(index), `"dir": "network/interface",`)
+	assert.Contains(t, string(index), `"project": "network",`)
+
+	page, err := renderCatalogHTML(catalog)
+	assert.Nil(t, err)
+	assert.Contains(t, page, `<tr data-search="vpc_id vpc_id &lt;b&gt;vpc&lt;/b&gt; aws_vpc.main.id deprecated " class="deprecated">`)
+	assert.Contains(t, page, "<td><code>aws_vpc.main (attribute id)</code></td>")
+	assert.Contains(t, page, "<td>network/main.tf:2</td>")
+	assert.Equal(t, "output zone_id in the producer's state", backingResource(ManifestOutput{Output: "zone_id", Strategy: strategyRemoteState}))
+}
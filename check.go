@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/afero"
+)
+
+// checkPlan diffs the files a plan would generate against the files on disk without writing anything. Generated files
+// in the interface directory that the plan would no longer generate are stale, and reported as deleted.
+func checkPlan(w io.Writer, fs afero.Fs, plan ProjectPlan, verbose bool) (bool, error) {
+	upToDate := true
+	for _, file := range plan.Files {
+		status, existing, err := fileStatus(fs, file)
+		if err != nil {
+			return false, err
+		}
+		if status == "unchanged" {
+			if verbose {
+				fmt.Fprintf(w, "\033[32mUp to date: %s\033[0m\n", file.Path)
+			}
+			continue
+		}
+		diff, err := unifiedDiff(file.Path, existing, file.Content)
+		if err != nil {
+			return false, err
+		}
+		upToDate = false
+		fmt.Fprint(w, diff)
+	}
+	stale, err := staleFiles(fs, plan)
+	if err != nil {
+		return false, err
+	}
+	for _, path := range stale {
+		existing, err := afero.ReadFile(fs, path)
+		if err != nil {
+			return false, err
+		}
+		diff, err := unifiedDiff(path, string(existing), "")
+		if err != nil {
+			return false, err
+		}
+		upToDate = false
+		fmt.Fprint(w, diff)
+		fmt.Fprintf(w, "\033[31mStale: %s is no longer generated, delete it\033[0m\n", path)
+	}
+	return upToDate, nil
+}
+
+// staleFiles lists the generated files on disk that a plan no longer generates, in its interface modules, in their
+// group submodules and in the audience modules that a previous run generated next to them.
+func staleFiles(fs afero.Fs, plan ProjectPlan) ([]string, error) {
+	if plan.InterfaceDir == "" {
+		return nil, nil
+	}
+	dirs, err := existingInterfaceDirs(fs, plan)
+	if err != nil {
+		return nil, err
+	}
+	for _, module := range plan.Interfaces {
+		dirs = appendUnique(dirs, module.Dir)
+	}
+	generated := make(map[string]bool)
+	for _, file := range plan.Files {
+		generated[file.Path] = true
+	}
+	var stale []string
+	for _, dir := range dirs {
+		for _, pattern := range []string{"generated_*", filepath.Join("modules", "*", "generated_*")} {
+			paths, err := afero.Glob(fs, filepath.Join(dir, pattern))
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range paths {
+				if !generated[path] {
+					stale = append(stale, path)
+				}
+			}
+		}
+	}
+	return stale, nil
+}
+
+func unifiedDiff(path string, existing string, generated string) (string, error) {
+	if existing == generated {
+		return "", nil
+	}
+	diff := difflib.UnifiedDiff{
+		FromFile: "a" + path,
+		ToFile:   "b" + path,
+		Context:  3,
+	}
+	if existing == "" {
+		diff.FromFile = "/dev/null"
+	} else {
+		diff.A = difflib.SplitLines(existing)
+	}
+	if generated == "" {
+		diff.ToFile = "/dev/null"
+	} else {
+		diff.B = difflib.SplitLines(generated)
+	}
+	return difflib.GetUnifiedDiffString(diff)
+}
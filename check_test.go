@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPlan(t *testing.T) {
+	command := fakeTerraform(t, t.TempDir())
+	tests := []struct {
+		name     string
+		change   func(dir string)
+		upToDate bool
+		diff     []string
+	}{
+		{
+			name:     "up to date",
+			change:   func(dir string) {},
+			upToDate: true,
+		},
+		{
+			name: "changed file",
+			change: func(dir string) {
+				os.WriteFile(filepath.Join(dir, "generated_outputs.tf"), []byte("output \"old\" {}\n"), 0644)
+			},
+			diff: []string{"--- a{dir}/generated_outputs.tf", "+++ b{dir}/generated_outputs.tf", "-output \"old\" {}", "+output \"output1\" {"},
+		},
+		{
+			name: "missing file",
+			change: func(dir string) {
+				os.Remove(filepath.Join(dir, "generated_data.tf"))
+			},
+			diff: []string{"--- /dev/null", "+++ b{dir}/generated_data.tf", "+data \"resource1\" \"instance1\" {"},
+		},
+		{
+			name: "stale extra file",
+			change: func(dir string) {
+				os.WriteFile(filepath.Join(dir, "generated_variables.tf"), []byte("variable \"old\" {}\n"), 0644)
+			},
+			diff: []string{"--- a{dir}/generated_variables.tf", "+++ /dev/null", "-variable \"old\" {}", "Stale: {dir}/generated_variables.tf is no longer generated"},
+		},
+		{
+			name: "stale audience module",
+			change: func(dir string) {
+				os.MkdirAll(dir+"-internal", 0755)
+				os.WriteFile(filepath.Join(dir+"-internal", "generated_outputs.tf"), []byte("output \"old\" {}\n"), 0644)
+			},
+			diff: []string{"--- a{dir}-internal/generated_outputs.tf", "Stale: {dir}-internal/generated_outputs.tf is no longer generated"},
+		},
+		{
+			name: "stale group submodule",
+			change: func(dir string) {
+				os.MkdirAll(filepath.Join(dir, "modules", "network"), 0755)
+				os.WriteFile(filepath.Join(dir, "modules", "network", "generated_outputs.tf"), []byte("output \"old\" {}\n"), 0644)
+				os.WriteFile(filepath.Join(dir, "generated_modules.tf"), []byte("module \"network\" {}\n"), 0644)
+			},
+			diff: []string{
+				"Stale: {dir}/modules/network/generated_outputs.tf is no longer generated",
+				"Stale: {dir}/generated_modules.tf is no longer generated",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			currentDir := t.TempDir()
+			project := ProjectConfig{Path: "project"}
+			os.MkdirAll(filepath.Join(currentDir, "project"), 0755)
+			afero.WriteFile(afero.NewOsFs(), filepath.Join(currentDir, "project", "main.tf"), []byte(testProject), 0644)
+			settings := ProjectSettings{Shell: "bash", Command: command}
+			plan, err := planProject(context.Background(), ExecRunner{}, afero.NewOsFs(), project, currentDir, settings, false)
+			assert.Nil(t, err)
+			assert.Nil(t, applyPlan(io.Discard, afero.NewOsFs(), plan, false))
+			interfaceDir := filepath.Join(currentDir, "project", "interface")
+			test.change(interfaceDir)
+
+			var out bytes.Buffer
+			plan, err = planProject(context.Background(), ExecRunner{}, afero.NewOsFs(), project, currentDir, settings, false)
+			assert.Nil(t, err)
+			upToDate, err := checkPlan(&out, afero.NewOsFs(), plan, false)
+			assert.Nil(t, err)
+			assert.Equal(t, test.upToDate, upToDate)
+			if test.upToDate {
+				assert.Empty(t, out.String())
+			}
+			for _, line := range test.diff {
+				assert.Contains(t, out.String(), strings.ReplaceAll(line, "{dir}", interfaceDir))
+			}
+
+			// generate writes what check reports and deletes the stale files.
+			assert.Nil(t, applyPlan(io.Discard, afero.NewOsFs(), plan, false))
+			upToDate, err = checkPlan(io.Discard, afero.NewOsFs(), plan, false)
+			assert.Nil(t, err)
+			assert.True(t, upToDate)
+			assert.NoDirExists(t, filepath.Join(interfaceDir, "modules"))
+		})
+	}
+}
@@ -0,0 +1,830 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+type Command struct {
+	Name    string
+	Usage   string
+	Summary string
+	Run     func(args []string) int
+}
+
+func commands() []Command {
+	return []Command{
+		{Name: "generate", Usage: "generate [flags]", Summary: "Generate interface modules for annotated outputs (default)", Run: runGenerate},
+		{Name: "check", Usage: "check [flags]", Summary: "Fail with a diff when the generated interfaces are out of date", Run: runCheck},
+		{Name: "verify", Usage: "verify [flags]", Summary: "Validate the generated interfaces in a temporary copy, and with -plan compare their values with the producers' state", Run: runVerify},
+		{Name: "list", Usage: "list [flags]", Summary: "List annotated outputs without reading state", Run: runList},
+		{Name: "lint", Usage: "lint [flags]", Summary: "Fail when the annotations would break the generated interfaces, e.g. by removing a stable output", Run: runLint},
+		{Name: "diff", Usage: "diff [flags] (-ref REF | -manifest FILE)", Summary: "Classify the interface changes since a git ref or a saved snapshot and suggest a version bump", Run: runDiff},
+		{Name: "explain", Usage: "explain [flags] <output>", Summary: "Trace how one annotated output is resolved to a data source", Run: runExplain},
+		{Name: "catalog", Usage: "catalog [serve] [flags]", Summary: "Build a browsable catalog of the generated interfaces, or serve it on localhost", Run: runCatalog},
+		{Name: "usage", Usage: "usage [flags]", Summary: "Show which consumers use each interface output and fail on references to missing outputs", Run: runUsage},
+		{Name: "graph", Usage: "graph [flags]", Summary: "Print which projects consume which interfaces as DOT, Mermaid or JSON, and fail on cycles", Run: runGraph},
+		{Name: "init", Usage: "init [flags]", Summary: "Scaffold a config.yaml from the Terraform roots below a directory", Run: runInit},
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: tf-interfaces <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.Name, cmd.Summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'tf-interfaces <command> -h' for the flags of a command.")
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	for _, cmd := range commands() {
+		if cmd.Name != name {
+			continue
+		}
+		flags := flag.NewFlagSet(name, flag.ContinueOnError)
+		flags.Usage = func() {
+			fmt.Fprintf(flags.Output(), "Usage: tf-interfaces %s\n\n%s.\n\nFlags:\n", cmd.Usage, cmd.Summary)
+			flags.PrintDefaults()
+		}
+		return flags
+	}
+	panic("unknown command " + name)
+}
+
+func parseFlags(flags *flag.FlagSet, args []string) (bool, int) {
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return false, 0
+		}
+		return false, 2
+	}
+	return true, 0
+}
+
+func runGenerate(args []string) int {
+	flags := newFlagSet("generate")
+	common := addCommonFlags(flags)
+	dryRun := flags.Bool("dry-run", false, "Print what would be generated, with a diff against disk, without writing anything")
+	reportFormat := flags.String("report", "", "Print a machine-readable report of the run to stdout (json)")
+	parallelism := flags.Int("parallelism", 0, "Number of projects to process at the same time (env TDI_PARALLELISM, default 1)")
+	topological := flags.Bool("topological", false, "Process a project only after the projects whose interfaces it consumes")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	mode := "generate"
+	if *dryRun {
+		mode = "dry-run"
+	}
+	return runProjects(mode, common, *reportFormat, *parallelism, *topological)
+}
+
+func runCheck(args []string) int {
+	flags := newFlagSet("check")
+	common := addCommonFlags(flags)
+	reportFormat := flags.String("report", "", "Print a machine-readable report of the run to stdout (json)")
+	parallelism := flags.Int("parallelism", 0, "Number of projects to process at the same time (env TDI_PARALLELISM, default 1)")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	return runProjects("check", common, *reportFormat, *parallelism, false)
+}
+
+func runLint(args []string) int {
+	flags := newFlagSet("lint")
+	common := addCommonFlags(flags)
+	reportFormat := flags.String("report", "", "Print a machine-readable report of the run to stdout (json)")
+	parallelism := flags.Int("parallelism", 0, "Number of projects to process at the same time (env TDI_PARALLELISM, default 1)")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	return runProjects("lint", common, *reportFormat, *parallelism, false)
+}
+
+func runVerify(args []string) int {
+	flags := newFlagSet("verify")
+	common := addCommonFlags(flags)
+	withPlan := flags.Bool("plan", false, "Also plan each interface against the real providers and compare its output values with the producer's state")
+	reportFormat := flags.String("report", "", "Print a machine-readable report of the run to stdout (json)")
+	parallelism := flags.Int("parallelism", 0, "Number of projects to process at the same time (env TDI_PARALLELISM, default 1)")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	mode := "verify"
+	if *withPlan {
+		mode = "verify-plan"
+	}
+	return runProjects(mode, common, *reportFormat, *parallelism, false)
+}
+
+// stringList is a flag that can be given several times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func runDiff(args []string) int {
+	flags := newFlagSet("diff")
+	common := addCommonFlags(flags)
+	ref := flags.String("ref", "", "Compare with the generated interfaces in this git ref")
+	manifest := flags.String("manifest", "", "Compare with a snapshot saved by -save")
+	save := flags.String("save", "", "Save a snapshot of the current interfaces to this file")
+	version := flags.String("version", "", "Current version of the interfaces, to print the next one")
+	allowBreaking := flags.Bool("allow-breaking", false, "Do not fail on breaking changes")
+	var approvals stringList
+	flags.Var(&approvals, "approve", "Approve the breaking changes to an output or interface, such as interface/vpc_id (repeatable)")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if *ref != "" && *manifest != "" {
+		log.Print("-ref and -manifest cannot be used together")
+		return 2
+	}
+	if *ref == "" && *manifest == "" && *save == "" {
+		log.Print("diff needs either -ref or -manifest")
+		return 2
+	}
+	opts, err := common.resolve(io.Discard)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if err := opts.checkExecutables(); err != nil {
+		log.Print(err)
+		return 1
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fs := afero.NewOsFs()
+	previous := Snapshot{}
+	if *manifest != "" {
+		previous, err = readSnapshot(fs, absolutePath(opts.CurrentDir, *manifest))
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+	}
+	current := Snapshot{}
+	var changes []Change
+	for _, project := range opts.Projects {
+		plan, err := planProject(ctx, opts.Runner, fs, project, opts.CurrentDir, opts.settingsFor(project), opts.Verbose)
+		if err != nil {
+			log.Printf("Failed to plan project %s: %v", project.Path, err)
+			return 1
+		}
+		dirs := []string{plan.InterfaceDir}
+		for _, module := range plan.Interfaces {
+			dirs = appendUnique(dirs, module.Dir)
+		}
+		if *ref != "" {
+			// An audience module that has no outputs anymore is missing from the plan, but not from the ref.
+			entries, err := gitDirEntries(opts.CurrentDir, *ref, filepath.Dir(plan.InterfaceDir))
+			if err != nil {
+				log.Printf("Failed to read the interfaces of project %s in %s: %v", project.Path, *ref, err)
+				return 1
+			}
+			for _, dir := range audienceInterfaceDirs(plan, entries) {
+				dirs = appendUnique(dirs, dir)
+			}
+		}
+		after, err := projectSnapshot(planReader(plan), opts.CurrentDir, project, dirs)
+		if err != nil {
+			log.Printf("Failed to read the interfaces of project %s: %v", project.Path, err)
+			return 1
+		}
+		current.Projects = append(current.Projects, after)
+		if *ref == "" && *manifest == "" {
+			continue
+		}
+		before := ProjectSnapshot{Path: project.Path}
+		if *ref != "" {
+			before, err = projectSnapshot(gitReader(opts.CurrentDir, *ref), opts.CurrentDir, project, dirs)
+			if err != nil {
+				log.Printf("Failed to read the interfaces of project %s in %s: %v", project.Path, *ref, err)
+				return 1
+			}
+		}
+		for _, snapshot := range previous.Projects {
+			if snapshot.Path == project.Path {
+				before = snapshot
+			}
+		}
+		projectChanges := diffSnapshots(before, after)
+		printChanges(os.Stdout, project.Path, projectChanges, approvals)
+		changes = append(changes, projectChanges...)
+	}
+	if *save != "" {
+		if err := writeSnapshot(fs, absolutePath(opts.CurrentDir, *save), current); err != nil {
+			log.Printf("Failed to save snapshot: %v", err)
+			return 1
+		}
+	}
+	if *ref == "" && *manifest == "" {
+		return 0
+	}
+	bump := suggestedBump(changes)
+	fmt.Printf("Suggested version bump: %s\n", bump)
+	if *version != "" {
+		next, err := nextVersion(*version, bump)
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+		fmt.Printf("Next version: %s\n", next)
+	}
+	unapproved := 0
+	for _, change := range changes {
+		if change.Kind == changeBreaking && !approved(change, approvals) {
+			unapproved++
+		}
+	}
+	if unapproved > 0 && !*allowBreaking {
+		fmt.Printf("\033[31m%d breaking change(s) are not approved. Approve them with -approve or pass -allow-breaking.\033[0m\n", unapproved)
+		return 1
+	}
+	return 0
+}
+
+type projectResult struct {
+	output    bytes.Buffer
+	report    ProjectReport
+	failed    bool
+	outOfDate bool
+	done      chan struct{}
+}
+
+func runProjects(mode string, common commonFlags, reportFormat string, parallelism int, topological bool) int {
+	if reportFormat != "" && reportFormat != "json" {
+		log.Printf("Unsupported report format: %s", reportFormat)
+		return 2
+	}
+	var status io.Writer = os.Stdout
+	if reportFormat != "" {
+		status = os.Stderr
+	}
+	opts, err := common.resolve(status)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if parallelism > 0 {
+		opts.Parallelism = parallelism
+	}
+	if err := opts.checkExecutables(); err != nil {
+		log.Print(err)
+		return 1
+	}
+	opts.print(status)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fs := afero.NewOsFs()
+	results := make([]*projectResult, len(opts.Projects))
+	byPath := make(map[string]*projectResult)
+	for i, project := range opts.Projects {
+		results[i] = &projectResult{done: make(chan struct{})}
+		byPath[project.Path] = results[i]
+	}
+	producers := make(map[string][]string)
+	if topological {
+		graph, err := buildProjectGraph(fs, opts.CurrentDir, opts.Projects)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+		for _, cycle := range graph.Cycles {
+			log.Printf("Projects consume each other's interfaces: %s", strings.Join(cycle, ", "))
+		}
+		if len(graph.Cycles) > 0 {
+			return 1
+		}
+		producers = graph.producers()
+	}
+	slots := make(chan struct{}, opts.Parallelism)
+	for i, project := range opts.Projects {
+		result := results[i]
+		go func(project ProjectConfig) {
+			for _, producer := range producers[project.Path] {
+				<-byPath[producer].done
+			}
+			slots <- struct{}{}
+			defer func() {
+				<-slots
+				close(result.done)
+			}()
+			runProject(ctx, result, mode, fs, opts, project, reportFormat != "")
+		}(project)
+	}
+	outOfDate := false
+	failed := false
+	report := Report{}
+	for _, result := range results {
+		<-result.done
+		status.Write(result.output.Bytes())
+		if reportFormat != "" {
+			report.Projects = append(report.Projects, result.report)
+		}
+		outOfDate = outOfDate || result.outOfDate
+		failed = failed || result.failed
+	}
+	if reportFormat != "" {
+		if err := writeReport(os.Stdout, report); err != nil {
+			log.Printf("Failed to write report: %v", err)
+			return 1
+		}
+	}
+	if outOfDate {
+		fmt.Fprintf(status, "\033[31mGenerated interfaces are out of date. Run 'tf-interfaces generate' to regenerate them.\033[0m\n")
+		return 1
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// runProject plans one project and checks, prints or writes it. Everything it prints goes to the result's buffer so
+// that projects running in parallel don't interleave their output.
+func runProject(ctx context.Context, result *projectResult, mode string, fs afero.Fs, opts options, project ProjectConfig, withReport bool) {
+	w := &result.output
+	fail := func(format string, args ...interface{}) {
+		fmt.Fprintf(w, "\033[31m"+format+"\033[0m\n", args...)
+		result.failed = true
+	}
+	plan, err := planProject(ctx, opts.Runner, fs, project, opts.CurrentDir, opts.settingsFor(project), opts.Verbose)
+	if withReport {
+		projectReport, reportErr := newProjectReport(fs, plan, err)
+		if reportErr != nil {
+			fail("Failed to build report for project %s: %v", project.Path, reportErr)
+		}
+		result.report = projectReport
+	}
+	if err != nil {
+		fail("Failed to plan project %s: %v", project.Path, err)
+		return
+	}
+	if mode != "dry-run" {
+		for _, problem := range plan.LintProblems {
+			fail("Lint: %s", problem)
+		}
+	}
+	// The plan that generate and dry-run print lists the skipped outputs itself.
+	if mode != "generate" && mode != "dry-run" {
+		for _, skipped := range plan.Skipped {
+			fmt.Fprintf(w, "\033[31mSkipping output %s (%s:%d): %s\033[0m\n", skipped.Output.Output, skipped.Output.File, skipped.Output.Line, skipped.Reason)
+		}
+	}
+	switch mode {
+	case "lint":
+		if len(plan.LintProblems) == 0 {
+			fmt.Fprintf(w, "\033[32mNo lint problems in project %s\033[0m\n", project.Path)
+		}
+	case "verify", "verify-plan":
+		problems, err := verifyPlan(ctx, opts.Runner, opts.settingsFor(project), plan, mode == "verify-plan")
+		if err != nil {
+			fail("Failed to verify project %s: %v", project.Path, err)
+			return
+		}
+		for _, problem := range problems {
+			fail("Verify: %s", problem)
+		}
+		if len(problems) == 0 {
+			fmt.Fprintf(w, "\033[32mVerified the interfaces of project %s\033[0m\n", project.Path)
+		}
+	case "check":
+		upToDate, err := checkPlan(w, fs, plan, opts.Verbose)
+		if err != nil {
+			fail("Failed to check project %s: %v", project.Path, err)
+		}
+		result.outOfDate = !upToDate
+	case "dry-run":
+		if err := printPlan(w, fs, plan, true); err != nil {
+			fail("Failed to print plan for project %s: %v", project.Path, err)
+		}
+	default:
+		if len(plan.LintProblems) > 0 {
+			fail("Not generating project %s", project.Path)
+			return
+		}
+		if err := applyPlan(w, fs, plan, opts.Verbose); err != nil {
+			fail("Failed to process project %s: %v", project.Path, err)
+		}
+	}
+}
+
+func runList(args []string) int {
+	flags := newFlagSet("list")
+	common := addCommonFlags(flags)
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	opts, err := common.resolve(io.Discard)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	fs := afero.NewOsFs()
+	for _, project := range opts.Projects {
+		fullPath := filepath.Join(opts.CurrentDir, project.Path)
+		fmt.Printf("\033[1;33mProject: %s\033[0m\n", fullPath)
+		outputs, err := projectOutputs(fs, fullPath, opts.Verbose)
+		if err != nil {
+			log.Printf("Failed to list project %s: %v", project.Path, err)
+			return 1
+		}
+		if len(outputs) == 0 {
+			fmt.Println("No Annotated Outputs")
+			continue
+		}
+		for _, output := range outputs {
+			fmt.Printf("  %s (%s:%d) = %s [%s]\n", displayName(output), output.File, output.Line, output.Reference, strings.Join(output.Annotation.Audiences, ", "))
+		}
+	}
+	return 0
+}
+
+func runExplain(args []string) int {
+	flags := newFlagSet("explain")
+	common := addCommonFlags(flags)
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	name := flags.Arg(0)
+	opts, err := common.resolve(io.Discard)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if err := opts.checkExecutables(); err != nil {
+		log.Print(err)
+		return 1
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fs := afero.NewOsFs()
+	found := false
+	for _, project := range opts.Projects {
+		fullPath := filepath.Join(opts.CurrentDir, project.Path)
+		outputs, err := projectOutputs(fs, fullPath, opts.Verbose)
+		if err != nil {
+			log.Printf("Failed to read project %s: %v", project.Path, err)
+			return 1
+		}
+		if !hasAnnotatedOutput(outputs, name) {
+			continue
+		}
+		found = true
+		plan, err := planProject(ctx, opts.Runner, fs, project, opts.CurrentDir, opts.settingsFor(project), opts.Verbose)
+		if err != nil {
+			log.Printf("Failed to plan project %s: %v", project.Path, err)
+			return 1
+		}
+		explainOutput(os.Stdout, plan, name)
+	}
+	if !found {
+		log.Printf("No annotated output named %s was found", name)
+		return 1
+	}
+	return 0
+}
+
+func hasAnnotatedOutput(outputs []AnnotatedOutput, name string) bool {
+	for _, output := range outputs {
+		if output.Output == name {
+			return true
+		}
+	}
+	return false
+}
+
+func explainOutput(w io.Writer, plan ProjectPlan, name string) {
+	for _, output := range plan.Outputs {
+		if output.Output != name {
+			continue
+		}
+		fmt.Fprintf(w, "\033[1;33mOutput %s in project %s\033[0m\n", output.Output, plan.FullPath)
+		fmt.Fprintf(w, "  Declared at:  %s:%d\n", output.File, output.Line)
+		fmt.Fprintf(w, "  Reference:    %s\n", output.Reference)
+		fmt.Fprintf(w, "  Audiences:    %s\n", strings.Join(output.Annotation.Audiences, ", "))
+		if output.Annotation.Group != "" {
+			fmt.Fprintf(w, "  Group:        %s\n", output.Annotation.Group)
+		}
+		if output.Annotation.Since != "" {
+			fmt.Fprintf(w, "  Since:        %s\n", output.Annotation.Since)
+		}
+		if output.Annotation.Stability != "" {
+			fmt.Fprintf(w, "  Stability:    %s\n", strings.TrimPrefix(stabilityComment(output.Annotation), "# "))
+		}
+		for _, skipped := range plan.Skipped {
+			if skipped.Output.Output == name {
+				fmt.Fprintf(w, "\033[31m  Skipped:      %s\033[0m\n", skipped.Reason)
+				return
+			}
+		}
+		if output.Annotation.Strategy == strategyRemoteState {
+			fmt.Fprintf(w, "  Remote state: data.terraform_remote_state.this (backend %s)\n", plan.RemoteState.Type)
+			fmt.Fprintf(w, "  Generated:    output %q { value = %s }\n", output.ExportedName(), outputValue(output))
+			continue
+		}
+		parts := strings.Split(output.Reference, ".")
+		resourceReference := parts[0] + "." + parts[1]
+		fmt.Fprintf(w, "  Resource:     %s (attribute %s)\n", resourceReference, parts[2])
+		for _, dataSource := range plan.DataSources {
+			if dataSource.Type+"."+dataSource.Name != resourceReference {
+				continue
+			}
+			fmt.Fprintf(w, "  Data source:  data.%s (provider %s)\n", resourceReference, dataSource.Provider)
+			if len(dataSource.Lookups) == 0 {
+				fmt.Fprintln(w, "  Lookup:       no required attributes")
+			}
+			for _, lookup := range dataSource.Lookups {
+				if lookup.Found {
+					fmt.Fprintf(w, "\033[32m  Lookup:       %s = %v (from %s in state)\033[0m\n", lookup.Attribute, displayValue(lookup), resourceReference)
+				} else {
+					fmt.Fprintf(w, "\033[31m  Lookup:       %s not found in the state of %s, read from var.%s\033[0m\n", lookup.Attribute, resourceReference, lookupVariable(dataSource, lookup))
+				}
+			}
+		}
+		fmt.Fprintf(w, "  Generated:    output %q { value = %s }\n", output.ExportedName(), outputValue(output))
+	}
+}
+
+func runCatalog(args []string) int {
+	serve := len(args) > 0 && args[0] == "serve"
+	if serve {
+		args = args[1:]
+	}
+	flags := newFlagSet("catalog")
+	common := addCommonFlags(flags)
+	output := flags.String("output", "catalog", "Directory to write index.html and index.json to")
+	addr := flags.String("addr", "localhost:8080", "Address to serve the catalog on with 'catalog serve'")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	opts, err := common.resolve(io.Discard)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	fs := afero.NewOsFs()
+	build := func() (Catalog, error) {
+		catalog, warnings, err := buildCatalog(fs, opts.CurrentDir, opts.Projects)
+		for _, warning := range warnings {
+			log.Printf("Warning: %s", warning)
+		}
+		return catalog, err
+	}
+	if serve {
+		return serveCatalog(*addr, build)
+	}
+	catalog, err := build()
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	page, err := renderCatalogHTML(catalog)
+	if err != nil {
+		log.Printf("Failed to render the catalog: %v", err)
+		return 1
+	}
+	index, err := renderCatalogJSON(catalog)
+	if err != nil {
+		log.Printf("Failed to render the catalog index: %v", err)
+		return 1
+	}
+	if err := fs.MkdirAll(*output, 0755); err != nil {
+		log.Printf("Failed to create %s: %v", *output, err)
+		return 1
+	}
+	for name, content := range map[string][]byte{"index.html": []byte(page), "index.json": index} {
+		if err := afero.WriteFile(fs, filepath.Join(*output, name), content, 0644); err != nil {
+			log.Printf("Failed to write %s: %v", name, err)
+			return 1
+		}
+	}
+	fmt.Printf("Wrote a catalog of %d interface module(s) to %s\n", len(catalog.Interfaces), *output)
+	return 0
+}
+
+// serveCatalog serves the catalog until interrupted. It is rebuilt for every request, so regenerated interfaces show
+// up on reload.
+func serveCatalog(addr string, build func() (Catalog, error)) int {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" && r.URL.Path != "/index.html" && r.URL.Path != "/index.json" {
+			http.NotFound(w, r)
+			return
+		}
+		catalog, err := build()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path == "/index.json" {
+			index, err := renderCatalogJSON(catalog)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(index)
+			return
+		}
+		page, err := renderCatalogHTML(catalog)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, page)
+	})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	fmt.Printf("Serving the catalog on http://%s (Ctrl+C to stop)\n", addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("Failed to serve the catalog: %v", err)
+		return 1
+	}
+	return 0
+}
+
+func runUsage(args []string) int {
+	flags := newFlagSet("usage")
+	common := addCommonFlags(flags)
+	reportFormat := flags.String("report", "", "Print the usage matrix as a machine-readable report to stdout (json)")
+	var consumers stringList
+	flags.Var(&consumers, "consumer", "Terraform root to scan for uses of the interfaces, instead of consumers in the config (repeatable)")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if *reportFormat != "" && *reportFormat != "json" {
+		log.Printf("Unsupported report format: %s", *reportFormat)
+		return 2
+	}
+	opts, err := common.resolve(io.Discard)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	roots := opts.Consumers
+	if len(consumers) > 0 {
+		roots = nil
+		for _, consumer := range consumers {
+			roots = append(roots, absolutePath(opts.CurrentDir, consumer))
+		}
+	}
+	if len(roots) == 0 {
+		log.Print("No consumers to scan: set consumers in the config or pass -consumer")
+		return 2
+	}
+	fs := afero.NewOsFs()
+	catalog, warnings, err := buildCatalog(fs, opts.CurrentDir, opts.Projects)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	for _, warning := range warnings {
+		log.Printf("Warning: %s", warning)
+	}
+	report, err := scanUsage(fs, opts.CurrentDir, catalog, roots)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	if *reportFormat != "" {
+		content, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Printf("Failed to write report: %v", err)
+			return 1
+		}
+		fmt.Println(string(content))
+	} else {
+		printUsageReport(os.Stdout, report)
+	}
+	for _, module := range report.Interfaces {
+		if len(module.Missing) > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+func runGraph(args []string) int {
+	flags := newFlagSet("graph")
+	common := addCommonFlags(flags)
+	format := flags.String("format", "dot", "Output format (dot, mermaid or json)")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	opts, err := common.resolve(io.Discard)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	graph, err := buildProjectGraph(afero.NewOsFs(), opts.CurrentDir, opts.Projects)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	content, err := renderGraph(graph, *format)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	fmt.Print(content)
+	for _, cycle := range graph.Cycles {
+		log.Printf("Projects consume each other's interfaces: %s", strings.Join(cycle, ", "))
+	}
+	if len(graph.Cycles) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func runInit(args []string) int {
+	flags := newFlagSet("init")
+	dir := flags.String("dir", ".", "Directory to search for Terraform roots")
+	output := flags.String("output", "config.yaml", "Path of the config file to write")
+	command := flags.String("command", "terraform", "Command to use to call terraform/tofu")
+	force := flags.Bool("force", false, "Overwrite the config file if it already exists")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	fs := afero.NewOsFs()
+	if _, err := fs.Stat(*output); err == nil && !*force {
+		log.Printf("%s already exists, use -force to overwrite it", *output)
+		return 1
+	}
+	roots, err := discoverTerraformRoots(fs, *dir)
+	if err != nil {
+		log.Printf("Failed to discover Terraform roots: %v", err)
+		return 1
+	}
+	// Project paths in a config file are relative to the directory of the config file.
+	found := len(roots)
+	if found == 0 {
+		roots = []string{*dir}
+	}
+	paths := make([]string, len(roots))
+	for i, root := range roots {
+		if paths[i], err = relativePath(filepath.Dir(*output), root); err != nil {
+			log.Printf("Failed to make %s relative to %s: %v", root, *output, err)
+			return 1
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintln(&b, "#shell: bash")
+	fmt.Fprintf(&b, "command: %s\n", *command)
+	fmt.Fprintln(&b, "#verbose: true")
+	fmt.Fprintln(&b, "projects:")
+	for _, path := range paths {
+		fmt.Fprintf(&b, "  - path: %s\n", filepath.ToSlash(path))
+	}
+	if err := afero.WriteFile(fs, *output, []byte(b.String()), 0644); err != nil {
+		log.Printf("Failed to write %s: %v", *output, err)
+		return 1
+	}
+	fmt.Printf("Wrote %s with %d project(s)\n", *output, found)
+	return 0
+}
+
+// relativePath returns target relative to base, where both are either absolute or relative to the working directory.
+func relativePath(base string, target string) (string, error) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(absBase, absTarget)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+// packageDir is the directory the tests started in.
+var packageDir, _ = os.Getwd()
+
+// runCommand runs a command in dir and returns its exit code and what it printed to stdout.
+func runCommand(t *testing.T, dir string, run func(args []string) int, args ...string) (int, string) {
+	assert.Nil(t, os.Chdir(dir))
+	defer os.Chdir(packageDir)
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	code := run(args)
+	os.Stdout = stdout
+	w.Close()
+	out, err := io.ReadAll(r)
+	assert.Nil(t, err)
+	return code, string(out)
+}
+
+func writeTestProject(t *testing.T) string {
+	dir := t.TempDir()
+	afero.WriteFile(afero.NewOsFs(), filepath.Join(dir, "main.tf"), []byte(testProject), 0644)
+	return dir
+}
+
+func TestRunList(t *testing.T) {
+	dir := writeTestProject(t)
+	code, out := runCommand(t, dir, runList)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "Project: "+dir)
+	assert.Contains(t, out, "  output1 (main.tf:9) = resource1.instance1.attribute1 [public]\n")
+	assert.Contains(t, out, "  output2 (main.tf:13) = resource2.instance2.attribute1 [public]\n")
+	assert.NotContains(t, out, "output3")
+
+	code, out = runCommand(t, t.TempDir(), runList)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "No Annotated Outputs")
+}
+
+func TestRunExplain(t *testing.T) {
+	dir := writeTestProject(t)
+	command := fakeTerraform(t, t.TempDir())
+	code, out := runCommand(t, dir, runExplain, "-command", command, "output1")
+	assert.Equal(t, 0, code)
+	for _, line := range []string{
+		"Output output1 in project " + dir,
+		"  Declared at:  main.tf:9\n",
+		"  Reference:    resource1.instance1.attribute1\n",
+		"  Resource:     resource1.instance1 (attribute attribute1)\n",
+		"  Data source:  data.resource1.instance1 (provider registry.terraform.io/hashicorp/provider1)\n",
+		"  Lookup:       no required attributes\n",
+		"  Generated:    output \"output1\" { value = data.resource1.instance1.attribute1 }\n",
+	} {
+		assert.Contains(t, out, line)
+	}
+	assert.NotContains(t, out, "output2")
+
+	code, _ = runCommand(t, dir, runExplain, "-command", command, "output3")
+	assert.Equal(t, 1, code)
+	code, _ = runCommand(t, dir, runExplain, "-command", command)
+	assert.Equal(t, 2, code)
+}
+
+func TestRunCheck(t *testing.T) {
+	dir := writeTestProject(t)
+	command := fakeTerraform(t, t.TempDir())
+	code, _ := runCommand(t, dir, runCheck, "-command", command)
+	assert.Equal(t, 1, code)
+	code, _ = runCommand(t, dir, runGenerate, "-command", command)
+	assert.Equal(t, 0, code)
+	code, out := runCommand(t, dir, runCheck, "-command", command)
+	assert.Equal(t, 0, code)
+	assert.NotContains(t, out, "out of date")
+
+	os.WriteFile(filepath.Join(dir, "interface", "generated_outputs.tf"), []byte("output \"old\" {}\n"), 0644)
+	code, out = runCommand(t, dir, runCheck, "-command", command)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, out, "-output \"old\" {}")
+	assert.Contains(t, out, "Generated interfaces are out of date")
+}
+
+func TestRunLintPrintsSkippedOutputs(t *testing.T) {
+	dir := t.TempDir()
+	afero.WriteFile(afero.NewOsFs(), filepath.Join(dir, "main.tf"), []byte(testProject+`
+resource "resource3" "instance3" {}
+# @public
+output "output4" {
+  value = resource3.instance3.attribute1
+}
+`), 0644)
+	code, out := runCommand(t, dir, runLint, "-command", fakeTerraform(t, t.TempDir()))
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "Skipping output output4 (main.tf:23): no data source matches resource type resource3")
+}
+
+func TestRunInit(t *testing.T) {
+	dir := t.TempDir()
+	fs := afero.NewOsFs()
+	for _, sub := range []string{"network", "app", "modules/vpc"} {
+		fs.MkdirAll(filepath.Join(dir, sub), 0755)
+	}
+	afero.WriteFile(fs, filepath.Join(dir, "network", "main.tf"), []byte("terraform {\n  backend \"s3\" {}\n}\n"), 0644)
+	afero.WriteFile(fs, filepath.Join(dir, "app", "main.tf"), []byte(testProject), 0644)
+	afero.WriteFile(fs, filepath.Join(dir, "modules", "vpc", "main.tf"), []byte("variable \"cidr\" {}\n"), 0644)
+
+	code, out := runCommand(t, dir, runInit, "-command", "tofu")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "Wrote config.yaml with 2 project(s)\n", out)
+	config, err := afero.ReadFile(fs, filepath.Join(dir, "config.yaml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(config), "command: tofu\n")
+	assert.Contains(t, string(config), "#shell: bash\n")
+	assert.NotContains(t, string(config), "\nshell:")
+	assert.Contains(t, string(config), "projects:\n  - path: app\n  - path: network\n")
+
+	code, _ = runCommand(t, dir, runInit)
+	assert.Equal(t, 1, code)
+	code, _ = runCommand(t, dir, runInit, "-force")
+	assert.Equal(t, 0, code)
+}
+
+func TestRunInitOutputInSubdirectory(t *testing.T) {
+	dir := t.TempDir()
+	fs := afero.NewOsFs()
+	fs.MkdirAll(filepath.Join(dir, "stacks", "app"), 0755)
+	fs.MkdirAll(filepath.Join(dir, "config"), 0755)
+	afero.WriteFile(fs, filepath.Join(dir, "stacks", "app", "main.tf"), []byte(testProject), 0644)
+
+	code, _ := runCommand(t, dir, runInit, "-dir", "stacks", "-output", "config/config.yaml")
+	assert.Equal(t, 0, code)
+	content, err := afero.ReadFile(fs, filepath.Join(dir, "config", "config.yaml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "projects:\n  - path: ../stacks/app\n")
+	assert.FileExists(t, filepath.Join(dir, "config", "../stacks/app", "main.tf"))
+
+	code, _ = runCommand(t, dir, runInit, "-dir", "config", "-output", "config/empty.yaml")
+	assert.Equal(t, 0, code)
+	content, err = afero.ReadFile(fs, filepath.Join(dir, "config", "empty.yaml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "projects:\n  - path: .\n")
+}
+
+func TestRunDiffRefRemovedAudience(t *testing.T) {
+	dir := t.TempDir()
+	command := fakeTerraform(t, t.TempDir())
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		output, err := cmd.CombinedOutput()
+		assert.Nil(t, err, string(output))
+	}
+	git("init", "-q")
+	afero.WriteFile(afero.NewOsFs(), filepath.Join(dir, "main.tf"), []byte(testProject+`
+# @internal
+output "output4" {
+  value = resource2.instance2.attribute1
+}
+`), 0644)
+	code, _ := runCommand(t, dir, runGenerate, "-command", command)
+	assert.Equal(t, 0, code)
+	git("add", "-A")
+	git("commit", "-q", "-m", "generate")
+
+	afero.WriteFile(afero.NewOsFs(), filepath.Join(dir, "main.tf"), []byte(testProject), 0644)
+	code, out := runCommand(t, dir, runDiff, "-command", command, "-ref", "HEAD")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, out, "interface-internal: interface module removed")
+	assert.Contains(t, out, "Suggested version bump: major")
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v2"
+)
+
+const configFileName = "config.yaml"
+
+type options struct {
+	Shell       string
+	Command     string
+	Env         map[string]string
+	VarFiles    []string
+	Workspace   string
+	Timeout     time.Duration
+	Parallelism int
+	Verbose     bool
+	Projects    []ProjectConfig
+	CurrentDir  string
+	ConfigPath  string
+	Terragrunt  string
+	Runner      Runner
+	Consumers   []string
+}
+
+type commonFlags struct {
+	flags       *flag.FlagSet
+	config      *string
+	shell       *string
+	projectPath *string
+	command     *string
+	verbose     *bool
+	replay      *string
+	record      *string
+}
+
+func addCommonFlags(flags *flag.FlagSet) commonFlags {
+	return commonFlags{
+		flags:       flags,
+		config:      flags.String("config", "", "Path to the config file (default: config.yaml in the current directory or the nearest parent)"),
+		shell:       flags.String("shell", "", "Shell to run commands through (env TDI_SHELL, default: run the command directly)"),
+		projectPath: flags.String("project-path", "", "Path to the Terraform project (env TDI_PROJECT_PATH)"),
+		command:     flags.String("command", "", "Command to use to call terraform/tofu (env TDI_COMMAND, default \"terraform\")"),
+		verbose:     flags.Bool("verbose", false, "Enable verbose output (env TDI_VERBOSE)"),
+		replay:      flags.String("replay", "", "Read terraform/tofu output from the fixtures in this directory instead of running it"),
+		record:      flags.String("record", "", "Save the output of every terraform/tofu command as a fixture in this directory"),
+	}
+}
+
+func readConfig(fs afero.Fs, path string) (Config, error) {
+	config := Config{}
+	content, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+	if strings.TrimSpace(string(content)) == "" {
+		return config, fmt.Errorf("config file %s is empty", path)
+	}
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return config, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	for i, project := range config.Projects {
+		if project.Path == "" {
+			return config, fmt.Errorf("invalid config file %s: projects[%d] has no path", path, i)
+		}
+		if !validUnresolvedPolicy(project.Unresolved) {
+			return config, fmt.Errorf("invalid config file %s: projects[%d] has unresolved %q, expected one of %s, %s, %s", path, i, project.Unresolved, unresolvedFail, unresolvedSkip, unresolvedVariable)
+		}
+	}
+	return config, nil
+}
+
+// findConfigFile looks for config.yaml in dir and then in each of its parents.
+func findConfigFile(fs afero.Fs, dir string) (string, bool) {
+	for {
+		path := filepath.Join(dir, configFileName)
+		if info, err := fs.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// resolve merges the flags, the TDI_* environment variables and the config file, in that order of precedence, over
+// the defaults.
+func (f commonFlags) resolve(status io.Writer) (options, error) {
+	fs := afero.NewOsFs()
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return options{}, fmt.Errorf("failed to get current directory: %v", err)
+	}
+	opts := options{Command: "terraform", Terragrunt: "terragrunt", Parallelism: 1, CurrentDir: workingDir}
+	setFlags := make(map[string]bool)
+	f.flags.Visit(func(fl *flag.Flag) {
+		setFlags[fl.Name] = true
+	})
+
+	configPath := os.Getenv("TDI_CONFIG")
+	if setFlags["config"] {
+		configPath = *f.config
+	}
+	if configPath == "" {
+		configPath, _ = findConfigFile(fs, workingDir)
+	} else {
+		configPath = absolutePath(workingDir, configPath)
+	}
+	config := Config{}
+	if configPath != "" {
+		config, err = readConfig(fs, configPath)
+		if err != nil {
+			return opts, err
+		}
+		opts.ConfigPath = configPath
+		fmt.Fprintf(status, "Using config file: %s\n", configPath)
+	} else {
+		fmt.Fprintf(status, "No config file found, using defaults.\n")
+	}
+
+	if config.Shell != "" {
+		opts.Shell = config.Shell
+	}
+	if config.Command != "" {
+		opts.Command = config.Command
+	}
+	if config.Terragrunt != "" {
+		opts.Terragrunt = config.Terragrunt
+	}
+	opts.Env = config.Env
+	opts.VarFiles = config.VarFiles
+	opts.Workspace = config.Workspace
+	opts.Timeout = config.Timeout
+	if config.Parallelism > 0 {
+		opts.Parallelism = config.Parallelism
+	}
+	opts.Verbose = config.Verbose
+	for _, consumer := range config.Consumers {
+		opts.Consumers = append(opts.Consumers, absolutePath(filepath.Dir(configPath), consumer))
+	}
+	if len(config.Projects) > 0 {
+		// Project paths in a config file are relative to the file, wherever it was found.
+		opts.CurrentDir = filepath.Dir(configPath)
+		opts.Projects, err = expandProjects(fs, opts.CurrentDir, config.Projects, config.Exclude)
+		if err != nil {
+			return opts, fmt.Errorf("failed to expand projects in %s: %v", configPath, err)
+		}
+		if len(opts.Projects) == 0 {
+			return opts, fmt.Errorf("no projects in %s matched any directory", configPath)
+		}
+	}
+
+	if value := os.Getenv("TDI_SHELL"); value != "" {
+		opts.Shell = value
+	}
+	if value := os.Getenv("TDI_COMMAND"); value != "" {
+		opts.Command = value
+	}
+	if value := os.Getenv("TDI_VERBOSE"); value != "" {
+		verbose, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, fmt.Errorf("invalid TDI_VERBOSE value %q: %v", value, err)
+		}
+		opts.Verbose = verbose
+	}
+	if value := os.Getenv("TDI_PARALLELISM"); value != "" {
+		parallelism, err := strconv.Atoi(value)
+		if err != nil || parallelism < 1 {
+			return opts, fmt.Errorf("invalid TDI_PARALLELISM value %q", value)
+		}
+		opts.Parallelism = parallelism
+	}
+	if value := os.Getenv("TDI_PROJECT_PATH"); value != "" {
+		opts.Projects = []ProjectConfig{{Path: value}}
+		opts.CurrentDir = workingDir
+	}
+
+	if setFlags["shell"] {
+		opts.Shell = *f.shell
+	}
+	if setFlags["command"] {
+		opts.Command = *f.command
+	}
+	if setFlags["verbose"] {
+		opts.Verbose = *f.verbose
+	}
+	if setFlags["project-path"] {
+		opts.Projects = []ProjectConfig{{Path: *f.projectPath}}
+		opts.CurrentDir = workingDir
+	}
+
+	if len(opts.Projects) == 0 {
+		opts.Projects = []ProjectConfig{{Path: "."}}
+	}
+	if *f.replay != "" && *f.record != "" {
+		return opts, fmt.Errorf("-replay and -record cannot be used together")
+	}
+	opts.Runner = ExecRunner{Verbose: opts.Verbose}
+	if *f.replay != "" {
+		opts.Runner = FixtureRunner{Fs: fs, Dir: absolutePath(workingDir, *f.replay), BaseDir: opts.CurrentDir}
+	} else if *f.record != "" {
+		opts.Runner = RecordingRunner{Runner: opts.Runner, Fs: fs, Dir: absolutePath(workingDir, *f.record), BaseDir: opts.CurrentDir}
+	}
+	return opts, nil
+}
+
+// settingsFor merges a project's overrides over the global settings.
+func (opts options) settingsFor(project ProjectConfig) ProjectSettings {
+	return projectSettings(project, ProjectSettings{
+		Shell:      opts.Shell,
+		Command:    opts.Command,
+		Env:        opts.Env,
+		VarFiles:   opts.VarFiles,
+		Workspace:  opts.Workspace,
+		Timeout:    opts.Timeout,
+		Terragrunt: opts.Terragrunt,
+	})
+}
+
+func absolutePath(workingDir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(workingDir, path)
+}
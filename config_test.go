@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadConfigRejectsUnknownKeys(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	readmeConfig := `
+shell: zsh
+terraform_project_paths:
+  - examples/simple
+use_tofu: true
+`
+	afero.WriteFile(fs, "readme_config.yaml", []byte(readmeConfig), 0644)
+	_, err := readConfig(fs, "readme_config.yaml")
+	assert.ErrorContains(t, err, "terraform_project_paths")
+
+	missingPath := `
+projects:
+  - generatedFolderName: api
+`
+	afero.WriteFile(fs, "missing_path_config.yaml", []byte(missingPath), 0644)
+	_, err = readConfig(fs, "missing_path_config.yaml")
+	assert.ErrorContains(t, err, "projects[0] has no path")
+}
+
+func TestFindConfigFile(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/repo/config.yaml", []byte("command: tofu\n"), 0644)
+	fs.MkdirAll("/repo/stacks/network", 0755)
+
+	path, found := findConfigFile(fs, "/repo/stacks/network")
+	assert.True(t, found)
+	assert.Equal(t, "/repo/config.yaml", path)
+
+	_, found = findConfigFile(fs, "/elsewhere")
+	assert.False(t, found)
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "tdi.yaml")
+	os.WriteFile(configPath, []byte(`
+shell: zsh
+command: tofu
+projects:
+  - path: stacks/network
+`), 0644)
+
+	resolve := func(args ...string) options {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		common := addCommonFlags(flags)
+		assert.Nil(t, flags.Parse(append([]string{"-config", configPath}, args...)))
+		opts, err := common.resolve(io.Discard)
+		assert.Nil(t, err)
+		return opts
+	}
+
+	opts := resolve()
+	assert.Equal(t, "zsh", opts.Shell)
+	assert.Equal(t, "tofu", opts.Command)
+	assert.Equal(t, dir, opts.CurrentDir)
+	assert.Equal(t, "stacks/network", opts.Projects[0].Path)
+
+	t.Setenv("TDI_COMMAND", "terragrunt")
+	t.Setenv("TDI_SHELL", "sh")
+	opts = resolve()
+	assert.Equal(t, "terragrunt", opts.Command)
+	assert.Equal(t, "sh", opts.Shell)
+
+	opts = resolve("-command", "terraform")
+	assert.Equal(t, "terraform", opts.Command)
+	assert.Equal(t, "sh", opts.Shell)
+}
+
+func TestProjectSettings(t *testing.T) {
+	defaults := ProjectSettings{
+		Shell:   "bash",
+		Command: "terraform",
+		Env:     map[string]string{"AWS_PROFILE": "default", "TF_IN_AUTOMATION": "1"},
+		Timeout: 2 * time.Minute,
+	}
+
+	settings := projectSettings(ProjectConfig{Path: "network"}, defaults)
+	assert.Equal(t, defaults, settings)
+
+	settings = projectSettings(ProjectConfig{
+		Path:      "dns",
+		Command:   "tofu",
+		Env:       map[string]string{"AWS_PROFILE": "dns", "TF_DATA_DIR": ".tfdata"},
+		VarFiles:  []string{"prod.tfvars"},
+		Workspace: "prod",
+		Timeout:   30 * time.Second,
+	}, defaults)
+	assert.Equal(t, ProjectSettings{
+		Shell:     "bash",
+		Command:   "tofu",
+		Env:       map[string]string{"AWS_PROFILE": "dns", "TF_DATA_DIR": ".tfdata", "TF_IN_AUTOMATION": "1"},
+		VarFiles:  []string{"prod.tfvars"},
+		Workspace: "prod",
+		Timeout:   30 * time.Second,
+	}, settings)
+	assert.Equal(t, "default", defaults.Env["AWS_PROFILE"])
+}
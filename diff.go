@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	changeBreaking = "breaking"
+	changeAdditive = "additive"
+	changePatch    = "patch"
+)
+
+// Snapshot is what the diff command compares: the signature of every generated interface module. It can be saved
+// as JSON and compared against later instead of a git ref.
+type Snapshot struct {
+	Projects []ProjectSnapshot `json:"projects"`
+}
+
+type ProjectSnapshot struct {
+	Path       string              `json:"path"`
+	Interfaces []InterfaceSnapshot `json:"interfaces"`
+}
+
+// InterfaceSnapshot is the signature of one interface module. Dir is relative to the directory that project paths
+// are relative to.
+type InterfaceSnapshot struct {
+	Dir       string             `json:"dir"`
+	Outputs   []OutputSnapshot   `json:"outputs"`
+	Variables []VariableSnapshot `json:"variables"`
+}
+
+type OutputSnapshot struct {
+	Name string `json:"name"`
+	// Value is the expression the module reads the output with, resolved through group submodules.
+	Value string `json:"value"`
+	// Type is the data source type the value comes from, or terraform_remote_state.
+	Type      string `json:"type"`
+	Stability string `json:"stability,omitempty"`
+}
+
+type VariableSnapshot struct {
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+}
+
+// Change is one difference between two versions of an interface module.
+type Change struct {
+	Kind    string
+	Subject string
+	Message string
+}
+
+var (
+	generatedValuePattern    = regexp.MustCompile(`^\s*value\s*=\s*(.+?)\s*$`)
+	generatedModulePattern   = regexp.MustCompile(`^module\s+"([^"]+)"`)
+	generatedVariablePattern = regexp.MustCompile(`^variable\s+"([^"]+)"`)
+	generatedDefaultPattern  = regexp.MustCompile(`^\s*default\s*=`)
+)
+
+// fileReader reads a file of a version of the tree. It reports false if the file does not exist in that version.
+type fileReader func(path string) (string, bool, error)
+
+func fsReader(fs afero.Fs) fileReader {
+	return func(path string) (string, bool, error) {
+		content, err := afero.ReadFile(fs, path)
+		if err != nil {
+			if exists, _ := afero.Exists(fs, path); !exists {
+				return "", false, nil
+			}
+			return "", false, err
+		}
+		return string(content), true, nil
+	}
+}
+
+// planReader reads the files a plan would generate.
+func planReader(plan ProjectPlan) fileReader {
+	files := make(map[string]string)
+	for _, file := range plan.Files {
+		files[file.Path] = file.Content
+	}
+	return func(path string) (string, bool, error) {
+		content, exists := files[path]
+		return content, exists, nil
+	}
+}
+
+// gitReader reads files as they are in a git ref, with paths below baseDir.
+func gitReader(baseDir string, ref string) fileReader {
+	return func(path string) (string, bool, error) {
+		relativePath, err := filepath.Rel(baseDir, path)
+		if err != nil {
+			return "", false, err
+		}
+		object := ref + ":./" + filepath.ToSlash(relativePath)
+		if err := exec.Command("git", "-C", baseDir, "cat-file", "-e", object).Run(); err != nil {
+			return "", false, nil
+		}
+		output, err := exec.Command("git", "-C", baseDir, "show", object).Output()
+		if err != nil {
+			return "", false, fmt.Errorf("git show %s failed: %v", object, err)
+		}
+		return string(output), true, nil
+	}
+}
+
+// gitDirEntries lists the entries of a directory below baseDir as it is in a git ref, or nothing if it does not exist
+// there.
+func gitDirEntries(baseDir string, ref string, dir string) ([]string, error) {
+	relativeDir, err := filepath.Rel(baseDir, dir)
+	if err != nil {
+		return nil, err
+	}
+	object := ref + ":./" + filepath.ToSlash(relativeDir)
+	if err := exec.Command("git", "-C", baseDir, "cat-file", "-e", object).Run(); err != nil {
+		return nil, nil
+	}
+	output, err := exec.Command("git", "-C", baseDir, "ls-tree", "--name-only", object).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-tree %s failed: %v", object, err)
+	}
+	return strings.Split(strings.TrimSuffix(string(output), "\n"), "\n"), nil
+}
+
+// readInterfaceSnapshot reads the signature of the interface module in dir, or reports false if there is none.
+func readInterfaceSnapshot(read fileReader, baseDir string, dir string) (InterfaceSnapshot, bool, error) {
+	relativeDir, err := filepath.Rel(baseDir, dir)
+	if err != nil {
+		return InterfaceSnapshot{}, false, err
+	}
+	snapshot := InterfaceSnapshot{Dir: relativeDir, Outputs: []OutputSnapshot{}, Variables: []VariableSnapshot{}}
+	outputs, found, err := read(filepath.Join(dir, "generated_outputs.tf"))
+	if err != nil || !found {
+		return snapshot, false, err
+	}
+	submodules := make(map[string][]OutputSnapshot)
+	modules, _, err := read(filepath.Join(dir, "generated_modules.tf"))
+	if err != nil {
+		return snapshot, false, err
+	}
+	for _, line := range strings.Split(modules, "\n") {
+		if matches := generatedModulePattern.FindStringSubmatch(line); matches != nil {
+			content, _, err := read(filepath.Join(dir, "modules", matches[1], "generated_outputs.tf"))
+			if err != nil {
+				return snapshot, false, err
+			}
+			submodules[matches[1]] = parseGeneratedOutputs(content)
+		}
+	}
+	for _, output := range parseGeneratedOutputs(outputs) {
+		if parts := strings.Split(output.Value, "."); len(parts) == 3 && parts[0] == "module" {
+			for _, submoduleOutput := range submodules[parts[1]] {
+				if submoduleOutput.Name == parts[2] {
+					output.Value, output.Type = submoduleOutput.Value, submoduleOutput.Type
+				}
+			}
+		}
+		snapshot.Outputs = append(snapshot.Outputs, output)
+	}
+	variables, _, err := read(filepath.Join(dir, "generated_variables.tf"))
+	if err != nil {
+		return snapshot, false, err
+	}
+	snapshot.Variables = parseGeneratedVariables(variables)
+	return snapshot, true, nil
+}
+
+// parseGeneratedOutputs reads the output blocks of a generated outputs file.
+func parseGeneratedOutputs(content string) []OutputSnapshot {
+	var outputs []OutputSnapshot
+	var current *OutputSnapshot
+	stability := ""
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if matches := generatedStabilityPattern.FindStringSubmatch(line); matches != nil {
+			stability = strings.ToLower(matches[1])
+		} else if matches := generatedOutputPattern.FindStringSubmatch(line); matches != nil {
+			outputs = append(outputs, OutputSnapshot{Name: matches[1], Stability: stability})
+			current = &outputs[len(outputs)-1]
+			stability = ""
+		} else if matches := generatedValuePattern.FindStringSubmatch(line); matches != nil && current != nil {
+			current.Value = matches[1]
+			current.Type = valueType(matches[1])
+			current = nil
+		}
+	}
+	return outputs
+}
+
+// valueType is the data source type an output value expression reads from.
+func valueType(value string) string {
+	parts := strings.Split(value, ".")
+	if len(parts) >= 3 && parts[0] == "data" {
+		return parts[1]
+	}
+	return ""
+}
+
+// parseGeneratedVariables reads the variable blocks of a generated file. A variable without a default is required.
+func parseGeneratedVariables(content string) []VariableSnapshot {
+	variables := []VariableSnapshot{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if matches := generatedVariablePattern.FindStringSubmatch(line); matches != nil {
+			variables = append(variables, VariableSnapshot{Name: matches[1], Required: true})
+		} else if generatedDefaultPattern.MatchString(line) && len(variables) > 0 {
+			variables[len(variables)-1].Required = false
+		}
+	}
+	return variables
+}
+
+// projectSnapshot reads the signature of the interface modules of a project in dirs, skipping dirs without one.
+func projectSnapshot(read fileReader, baseDir string, project ProjectConfig, dirs []string) (ProjectSnapshot, error) {
+	snapshot := ProjectSnapshot{Path: project.Path, Interfaces: []InterfaceSnapshot{}}
+	for _, dir := range dirs {
+		interfaceSnapshot, found, err := readInterfaceSnapshot(read, baseDir, dir)
+		if err != nil {
+			return snapshot, err
+		}
+		if found {
+			snapshot.Interfaces = append(snapshot.Interfaces, interfaceSnapshot)
+		}
+	}
+	return snapshot, nil
+}
+
+// diffSnapshots classifies the changes from previous to current. Removing an output or an interface, changing the
+// data source type an output reads, and adding a required variable are breaking. Adding outputs or optional variables
+// and deprecating outputs are additive. Any other change to an output is a patch.
+func diffSnapshots(previous ProjectSnapshot, current ProjectSnapshot) []Change {
+	var changes []Change
+	currentInterfaces := make(map[string]InterfaceSnapshot)
+	for _, module := range current.Interfaces {
+		currentInterfaces[module.Dir] = module
+	}
+	previousInterfaces := make(map[string]bool)
+	for _, before := range previous.Interfaces {
+		previousInterfaces[before.Dir] = true
+		after, exists := currentInterfaces[before.Dir]
+		if !exists {
+			changes = append(changes, Change{Kind: changeBreaking, Subject: before.Dir, Message: "interface module removed"})
+			continue
+		}
+		changes = append(changes, diffInterfaces(before, after)...)
+	}
+	for _, after := range current.Interfaces {
+		if !previousInterfaces[after.Dir] {
+			changes = append(changes, Change{Kind: changeAdditive, Subject: after.Dir, Message: "interface module added"})
+		}
+	}
+	return changes
+}
+
+func diffInterfaces(before InterfaceSnapshot, after InterfaceSnapshot) []Change {
+	var changes []Change
+	subject := func(name string) string {
+		return filepath.ToSlash(filepath.Join(before.Dir, name))
+	}
+	afterOutputs := make(map[string]OutputSnapshot)
+	for _, output := range after.Outputs {
+		afterOutputs[output.Name] = output
+	}
+	beforeOutputs := make(map[string]bool)
+	for _, old := range before.Outputs {
+		beforeOutputs[old.Name] = true
+		updated, exists := afterOutputs[old.Name]
+		switch {
+		case !exists:
+			changes = append(changes, Change{Kind: changeBreaking, Subject: subject(old.Name), Message: "output removed"})
+		case old.Type != updated.Type:
+			changes = append(changes, Change{Kind: changeBreaking, Subject: subject(old.Name), Message: fmt.Sprintf("type changed from %s to %s", typeName(old.Type), typeName(updated.Type))})
+		case old.Stability != stabilityDeprecated && updated.Stability == stabilityDeprecated:
+			changes = append(changes, Change{Kind: changeAdditive, Subject: subject(old.Name), Message: "output deprecated"})
+		case old.Value != updated.Value:
+			changes = append(changes, Change{Kind: changePatch, Subject: subject(old.Name), Message: fmt.Sprintf("value changed from %s to %s", old.Value, updated.Value)})
+		case old.Stability != updated.Stability:
+			changes = append(changes, Change{Kind: changePatch, Subject: subject(old.Name), Message: fmt.Sprintf("stability changed from %s to %s", typeName(old.Stability), typeName(updated.Stability))})
+		}
+	}
+	for _, output := range after.Outputs {
+		if !beforeOutputs[output.Name] {
+			changes = append(changes, Change{Kind: changeAdditive, Subject: subject(output.Name), Message: "output added"})
+		}
+	}
+	beforeVariables := make(map[string]VariableSnapshot)
+	for _, variable := range before.Variables {
+		beforeVariables[variable.Name] = variable
+	}
+	for _, variable := range after.Variables {
+		old, existed := beforeVariables[variable.Name]
+		switch {
+		case variable.Required && (!existed || !old.Required):
+			changes = append(changes, Change{Kind: changeBreaking, Subject: subject("var." + variable.Name), Message: "new required variable"})
+		case !existed:
+			changes = append(changes, Change{Kind: changeAdditive, Subject: subject("var." + variable.Name), Message: "new optional variable"})
+		}
+	}
+	return changes
+}
+
+func typeName(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+// suggestedBump is the semantic version bump the most severe change needs: major, minor, patch or none.
+func suggestedBump(changes []Change) string {
+	bump := "none"
+	for _, change := range changes {
+		switch change.Kind {
+		case changeBreaking:
+			return "major"
+		case changeAdditive:
+			bump = "minor"
+		case changePatch:
+			if bump == "none" {
+				bump = "patch"
+			}
+		}
+	}
+	return bump
+}
+
+// nextVersion applies a bump to a version such as 1.2.3 or v1.2.3.
+func nextVersion(version string, bump string) (string, error) {
+	prefix := ""
+	if strings.HasPrefix(version, "v") {
+		prefix, version = "v", version[1:]
+	}
+	parts := strings.Split(strings.SplitN(version, "-", 2)[0], ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("version %q is not a semantic version such as 1.2.3", version)
+	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return "", fmt.Errorf("version %q is not a semantic version such as 1.2.3", version)
+		}
+		numbers[i] = number
+	}
+	switch bump {
+	case "major":
+		numbers = []int{numbers[0] + 1, 0, 0}
+	case "minor":
+		numbers = []int{numbers[0], numbers[1] + 1, 0}
+	case "patch":
+		numbers[2]++
+	}
+	return fmt.Sprintf("%s%d.%d.%d", prefix, numbers[0], numbers[1], numbers[2]), nil
+}
+
+// approved reports whether a breaking change was approved, by its subject or by the interface it is in.
+func approved(change Change, approvals []string) bool {
+	for _, approval := range approvals {
+		if approval == change.Subject || strings.HasPrefix(change.Subject, strings.TrimSuffix(approval, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func printChanges(w io.Writer, project string, changes []Change, approvals []string) {
+	fmt.Fprintf(w, "\033[1;33mProject: %s\033[0m\n", project)
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "No changes")
+		return
+	}
+	sorted := append([]Change{}, changes...)
+	order := map[string]int{changeBreaking: 0, changeAdditive: 1, changePatch: 2}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return order[sorted[i].Kind] < order[sorted[j].Kind]
+	})
+	for _, change := range sorted {
+		color := "\033[32m"
+		suffix := ""
+		if change.Kind == changeBreaking {
+			color = "\033[31m"
+			if approved(change, approvals) {
+				suffix = " (approved)"
+			}
+		}
+		fmt.Fprintf(w, "%s  %-8s %s: %s%s\033[0m\n", color, change.Kind, change.Subject, change.Message, suffix)
+	}
+}
+
+func readSnapshot(fs afero.Fs, path string) (Snapshot, error) {
+	var snapshot Snapshot
+	content, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("invalid snapshot %s: %v", path, err)
+	}
+	return snapshot, nil
+}
+
+func writeSnapshot(fs afero.Fs, path string, snapshot Snapshot) error {
+	content, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return afero.WriteFile(fs, path, append(content, '\n'), 0644)
+}
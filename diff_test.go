@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	previous := ProjectSnapshot{Path: ".", Interfaces: []InterfaceSnapshot{
+		{Dir: "interface", Outputs: []OutputSnapshot{
+			{Name: "vpc_id", Value: "data.aws_vpc.main.id", Type: "aws_vpc", Stability: stabilityStable},
+			{Name: "subnet_id", Value: "data.aws_subnet.main.id", Type: "aws_subnet"},
+			{Name: "zone_id", Value: "data.aws_route53_zone.main.zone_id", Type: "aws_route53_zone"},
+			{Name: "cidr", Value: "data.aws_vpc.main.cidr_block", Type: "aws_vpc"},
+		}, Variables: []VariableSnapshot{{Name: "region", Required: false}}},
+		{Dir: "interface-internal"},
+	}}
+	current := ProjectSnapshot{Path: ".", Interfaces: []InterfaceSnapshot{
+		{Dir: "interface", Outputs: []OutputSnapshot{
+			{Name: "vpc_id", Value: "data.aws_vpc.this.id", Type: "aws_vpc", Stability: stabilityStable},
+			{Name: "subnet_id", Value: "data.aws_subnet.main.id", Type: "aws_subnet", Stability: stabilityDeprecated},
+			{Name: "zone_id", Value: "data.terraform_remote_state.this.outputs.zone_id"},
+			{Name: "name", Value: "data.aws_vpc.main.tags", Type: "aws_vpc"},
+		}, Variables: []VariableSnapshot{{Name: "region", Required: true}, {Name: "tags"}}},
+		{Dir: "interface-team-a"},
+	}}
+
+	changes := diffSnapshots(previous, current)
+	assert.Equal(t, []Change{
+		{Kind: changePatch, Subject: "interface/vpc_id", Message: "value changed from data.aws_vpc.main.id to data.aws_vpc.this.id"},
+		{Kind: changeAdditive, Subject: "interface/subnet_id", Message: "output deprecated"},
+		{Kind: changeBreaking, Subject: "interface/zone_id", Message: "type changed from aws_route53_zone to none"},
+		{Kind: changeBreaking, Subject: "interface/cidr", Message: "output removed"},
+		{Kind: changeAdditive, Subject: "interface/name", Message: "output added"},
+		{Kind: changeBreaking, Subject: "interface/var.region", Message: "new required variable"},
+		{Kind: changeAdditive, Subject: "interface/var.tags", Message: "new optional variable"},
+		{Kind: changeBreaking, Subject: "interface-internal", Message: "interface module removed"},
+		{Kind: changeAdditive, Subject: "interface-team-a", Message: "interface module added"},
+	}, changes)
+	assert.Equal(t, "major", suggestedBump(changes))
+	assert.True(t, approved(changes[2], []string{"interface/zone_id"}))
+	assert.True(t, approved(changes[3], []string{"interface/"}))
+	assert.False(t, approved(changes[7], []string{"interface"}))
+
+	assert.Equal(t, "minor", suggestedBump(changes[1:2]))
+	assert.Equal(t, "patch", suggestedBump(changes[:1]))
+	assert.Equal(t, "none", suggestedBump(nil))
+}
+
+func TestNextVersion(t *testing.T) {
+	for version, bumps := range map[string]map[string]string{
+		"1.2.3":        {"major": "2.0.0", "minor": "1.3.0", "patch": "1.2.4", "none": "1.2.3"},
+		"v0.4.1-beta1": {"major": "v1.0.0", "minor": "v0.5.0"},
+	} {
+		for bump, next := range bumps {
+			actual, err := nextVersion(version, bump)
+			assert.Nil(t, err)
+			assert.Equal(t, next, actual, version+" "+bump)
+		}
+	}
+	_, err := nextVersion("1.2", "major")
+	assert.EqualError(t, err, `version "1.2" is not a semantic version such as 1.2.3`)
+}
+
+func TestPlanSnapshot(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	state, err := os.ReadFile("testdata/fixtures/examples/simple/show.json")
+	assert.Nil(t, err)
+	schema, err := os.ReadFile("testdata/fixtures/examples/simple/providers_schema.json")
+	assert.Nil(t, err)
+	afero.WriteFile(fs, "/fixtures/show.json", state, 0644)
+	afero.WriteFile(fs, "/fixtures/providers_schema.json", schema, 0644)
+	afero.WriteFile(fs, "/project/main.tf", []byte(`# @public(group="files") @stable
+output "local_file_path" {
+  value = local_file.my_local_file.filename
+}
+
+# @public
+output "local_file_id" {
+  value = local_file.my_local_file.id
+}
+`), 0644)
+	runner := FixtureRunner{Fs: fs, Dir: "/fixtures", BaseDir: "/project"}
+	project := ProjectConfig{Path: "."}
+
+	plan, err := planProject(context.Background(), runner, fs, project, "/project", ProjectSettings{Command: "terraform"}, false)
+	assert.Nil(t, err)
+	snapshot, err := projectSnapshot(planReader(plan), "/project", project, []string{"/project/interface", "/project/interface-internal"})
+	assert.Nil(t, err)
+	assert.Equal(t, ProjectSnapshot{Path: ".", Interfaces: []InterfaceSnapshot{{
+		Dir: "interface",
+		Outputs: []OutputSnapshot{
+			{Name: "local_file_path", Value: "data.local_file.my_local_file.filename", Type: "local_file", Stability: stabilityStable},
+			{Name: "local_file_id", Value: "data.local_file.my_local_file.id", Type: "local_file"},
+		},
+		Variables: []VariableSnapshot{},
+	}}}, snapshot)
+
+	assert.Nil(t, writePlan(fs, plan, false))
+	onDisk, err := projectSnapshot(fsReader(fs), "/project", project, []string{"/project/interface"})
+	assert.Nil(t, err)
+	assert.Equal(t, snapshot, onDisk)
+
+	assert.Nil(t, writeSnapshot(fs, "/snapshot.json", Snapshot{Projects: []ProjectSnapshot{snapshot}}))
+	saved, err := readSnapshot(fs, "/snapshot.json")
+	assert.Nil(t, err)
+	assert.Equal(t, snapshot, saved.Projects[0])
+}
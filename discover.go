@@ -0,0 +1,205 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+func terraformFiles(fs afero.Fs, dir string) ([]string, error) {
+	entries, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".tf") || strings.HasPrefix(entry.Name(), "generated_") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	return files, nil
+}
+
+// isTerraformRoot reports whether a directory is a Terragrunt unit or has .tf files with a backend block or a
+// visibility tag.
+func isTerraformRoot(fs afero.Fs, dir string) (bool, error) {
+	if isTerragruntUnit(fs, dir) {
+		return true, nil
+	}
+	files, err := terraformFiles(fs, dir)
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		content, err := afero.ReadFile(fs, file)
+		if err != nil {
+			return false, err
+		}
+		if strings.Contains(string(content), "backend \"") || hasAnnotationTag(string(content)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func discoverTerraformRoots(fs afero.Fs, root string) ([]string, error) {
+	var roots []string
+	err := walkDirectories(fs, root, nil, func(dir string) error {
+		isRoot, err := isTerraformRoot(fs, dir)
+		if isRoot {
+			roots = append(roots, dir)
+		}
+		return err
+	})
+	sort.Strings(roots)
+	return roots, err
+}
+
+// walkDirectories calls fn for every directory below root, skipping hidden directories and any directory whose path
+// matches one of the exclude patterns.
+func walkDirectories(fs afero.Fs, root string, exclude []string, fn func(dir string) error) error {
+	return afero.Walk(fs, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		for _, pattern := range exclude {
+			if matchGlob(pattern, path) {
+				return filepath.SkipDir
+			}
+		}
+		return fn(filepath.Clean(path))
+	})
+}
+
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// matchGlob matches a slash-separated path against a pattern in which "**" stands for any number of directories and
+// every other segment follows filepath.Match.
+func matchGlob(pattern string, path string) bool {
+	return matchSegments(splitPath(pattern), splitPath(path))
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(filepath.ToSlash(filepath.Clean(path)), "/")
+	if path == "" || path == "." {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func matchSegments(pattern []string, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if matched, err := filepath.Match(pattern[0], path[0]); err != nil || !matched {
+		return false
+	}
+	return matchSegments(pattern[1:], path[1:])
+}
+
+func globBase(pattern string) string {
+	var base []string
+	for _, segment := range splitPath(pattern) {
+		if isGlob(segment) {
+			break
+		}
+		base = append(base, segment)
+	}
+	if len(base) == 0 {
+		return "."
+	}
+	return filepath.Join(base...)
+}
+
+// expandProjects turns glob and discover entries into one project per matching directory. Paths are relative to
+// baseDir. The settings of a glob or discover entry are defaults for the projects it expands to, and an entry
+// listed explicitly takes precedence over one found by a glob.
+func expandProjects(fs afero.Fs, baseDir string, projects []ProjectConfig, exclude []string) ([]ProjectConfig, error) {
+	var expanded []ProjectConfig
+	seen := make(map[string]int)
+	add := func(project ProjectConfig, explicit bool) {
+		project.Path = filepath.Clean(project.Path)
+		if index, exists := seen[project.Path]; exists {
+			if explicit {
+				expanded[index] = project
+			}
+			return
+		}
+		seen[project.Path] = len(expanded)
+		expanded = append(expanded, project)
+	}
+	for _, project := range projects {
+		if !project.Discover && !isGlob(project.Path) {
+			add(project, true)
+			continue
+		}
+		root := project.Path
+		if isGlob(root) {
+			root = globBase(root)
+		}
+		var excludePatterns []string
+		for _, pattern := range append(append([]string{}, exclude...), project.Exclude...) {
+			excludePatterns = append(excludePatterns, filepath.Join(baseDir, pattern))
+		}
+		var matches []string
+		err := walkDirectories(fs, filepath.Join(baseDir, root), excludePatterns, func(dir string) error {
+			relativePath, err := filepath.Rel(baseDir, dir)
+			if err != nil {
+				return err
+			}
+			if isGlob(project.Path) && !matchGlob(project.Path, relativePath) {
+				return nil
+			}
+			var matched bool
+			if project.Discover {
+				matched, err = isTerraformRoot(fs, dir)
+			} else {
+				files, filesErr := terraformFiles(fs, dir)
+				matched, err = len(files) > 0 || isTerragruntUnit(fs, dir), filesErr
+			}
+			if matched {
+				matches = append(matches, relativePath)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			expandedProject := project
+			expandedProject.Path = match
+			expandedProject.Discover = false
+			expandedProject.Exclude = nil
+			if project.GeneratedFolderPath != "" {
+				// Mirror each project below the folder path so matches don't overwrite each other.
+				expandedProject.GeneratedFolderPath = filepath.Join(project.GeneratedFolderPath, match)
+			}
+			add(expandedProject, false)
+		}
+	}
+	return expanded, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchGlob(t *testing.T) {
+	assert.True(t, matchGlob("stacks/**/", "stacks/network/prod"))
+	assert.True(t, matchGlob("stacks/**", "stacks"))
+	assert.True(t, matchGlob("stacks/*/prod", "stacks/network/prod"))
+	assert.False(t, matchGlob("stacks/*/prod", "stacks/network/eu/prod"))
+	assert.True(t, matchGlob("**/modules/**", "stacks/network/modules/vpc"))
+	assert.False(t, matchGlob("stacks/**", "other/network"))
+}
+
+func TestExpandProjects(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/repo/stacks/network/main.tf", []byte("terraform {\n  backend \"s3\" {}\n}\n"), 0644)
+	afero.WriteFile(fs, "/repo/stacks/network/interface/generated_outputs.tf", []byte(""), 0644)
+	afero.WriteFile(fs, "/repo/stacks/dns/main.tf", []byte("# @public\noutput \"zone\" {\n  value = aws_route53_zone.main.id\n}\n"), 0644)
+	afero.WriteFile(fs, "/repo/stacks/dns/modules/records/main.tf", []byte("variable \"zone\" {}\n"), 0644)
+	afero.WriteFile(fs, "/repo/stacks/legacy/main.tf", []byte("terraform {\n  backend \"local\" {}\n}\n"), 0644)
+	afero.WriteFile(fs, "/repo/apps/web/main.tf", []byte("terraform {\n  backend \"s3\" {}\n}\n"), 0644)
+	afero.WriteFile(fs, "/repo/apps/web/.terraform/modules/x/main.tf", []byte("terraform {\n  backend \"s3\" {}\n}\n"), 0644)
+
+	t.Run("Glob with defaults and excludes", func(t *testing.T) {
+		projects, err := expandProjects(fs, "/repo", []ProjectConfig{
+			{Path: "stacks/**/", GeneratedFolderName: "api", GeneratedFolderPath: "interfaces", Exclude: []string{"stacks/legacy"}},
+		}, []string{"**/modules/**"})
+		assert.Nil(t, err)
+		assert.Equal(t, []ProjectConfig{
+			{Path: "stacks/dns", GeneratedFolderName: "api", GeneratedFolderPath: "interfaces/stacks/dns"},
+			{Path: "stacks/network", GeneratedFolderName: "api", GeneratedFolderPath: "interfaces/stacks/network"},
+		}, projects)
+	})
+
+	t.Run("Discovery finds roots and explicit entries win", func(t *testing.T) {
+		projects, err := expandProjects(fs, "/repo", []ProjectConfig{
+			{Path: "apps/web", GeneratedFolderName: "web-interface"},
+			{Path: ".", Discover: true},
+		}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, []ProjectConfig{
+			{Path: "apps/web", GeneratedFolderName: "web-interface"},
+			{Path: "stacks/dns"},
+			{Path: "stacks/legacy"},
+			{Path: "stacks/network"},
+		}, projects)
+	})
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// ProjectGraph is which projects consume the interfaces of which other projects.
+type ProjectGraph struct {
+	Projects []string    `json:"projects"`
+	Edges    []GraphEdge `json:"edges"`
+	// Order lists the projects so that every project comes after the projects it consumes. It is empty when there
+	// are cycles.
+	Order  []string   `json:"order"`
+	Cycles [][]string `json:"cycles"`
+}
+
+// GraphEdge is a consumer project calling an interface module of a producer project.
+type GraphEdge struct {
+	Consumer  string `json:"consumer"`
+	Producer  string `json:"producer"`
+	Interface string `json:"interface"`
+	File      string `json:"file"`
+}
+
+// projectInterfaces lists the interface modules the annotations of the projects call for, whether or not they have
+// been generated yet.
+func projectInterfaces(fs afero.Fs, currentDir string, projects []ProjectConfig) (Catalog, error) {
+	catalog := Catalog{}
+	for _, project := range projects {
+		outputs, err := projectOutputs(fs, filepath.Join(currentDir, project.Path), false)
+		if err != nil {
+			return catalog, fmt.Errorf("failed to read project %s: %v", project.Path, err)
+		}
+		for _, audience := range sortedAudiences(outputs) {
+			dir := relativeTo(currentDir, interfaceDirectory(currentDir, project, audience))
+			catalog.Interfaces = append(catalog.Interfaces, CatalogEntry{Dir: dir, Manifest: Manifest{Project: project.Path, Audience: audience}})
+		}
+	}
+	return catalog, nil
+}
+
+// buildProjectGraph finds the module blocks in each project that source an interface module of another project.
+func buildProjectGraph(fs afero.Fs, currentDir string, projects []ProjectConfig) (ProjectGraph, error) {
+	graph := ProjectGraph{Projects: []string{}, Edges: []GraphEdge{}, Order: []string{}, Cycles: [][]string{}}
+	catalog, err := projectInterfaces(fs, currentDir, projects)
+	if err != nil {
+		return graph, err
+	}
+	for _, project := range projects {
+		graph.Projects = append(graph.Projects, project.Path)
+		dir, err := projectModuleDir(fs, filepath.Join(currentDir, project.Path), false)
+		if err != nil {
+			return graph, err
+		}
+		files, err := afero.Glob(fs, filepath.Join(dir, "*.tf"))
+		if err != nil {
+			return graph, err
+		}
+		seen := make(map[string]bool)
+		for _, file := range files {
+			calls := make(map[string]moduleCall)
+			if err := scanModuleCalls(fs, file, dir, currentDir, catalog, calls); err != nil {
+				return graph, err
+			}
+			for _, name := range sortedCallNames(calls) {
+				entry := catalog.Interfaces[calls[name].entry]
+				if seen[entry.Dir] {
+					continue
+				}
+				seen[entry.Dir] = true
+				graph.Edges = append(graph.Edges, GraphEdge{Consumer: project.Path, Producer: entry.Project, Interface: entry.Dir, File: relativeTo(currentDir, file)})
+			}
+		}
+	}
+	graph.Cycles = graphCycles(graph)
+	if len(graph.Cycles) == 0 {
+		graph.Order = topologicalOrder(graph)
+	}
+	return graph, nil
+}
+
+func sortedCallNames(calls map[string]moduleCall) []string {
+	var names []string
+	for name := range calls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// producers maps each project to the projects whose interfaces it consumes.
+func (g ProjectGraph) producers() map[string][]string {
+	producers := make(map[string][]string)
+	for _, edge := range g.Edges {
+		producers[edge.Consumer] = appendUnique(producers[edge.Consumer], edge.Producer)
+	}
+	return producers
+}
+
+// topologicalOrder orders the projects producers first, keeping the config order between projects that don't
+// depend on each other.
+func topologicalOrder(g ProjectGraph) []string {
+	producers := g.producers()
+	done := make(map[string]bool)
+	order := []string{}
+	for len(order) < len(g.Projects) {
+		progress := false
+		for _, project := range g.Projects {
+			if done[project] {
+				continue
+			}
+			ready := true
+			for _, producer := range producers[project] {
+				ready = ready && done[producer]
+			}
+			if ready {
+				done[project] = true
+				order = append(order, project)
+				progress = true
+			}
+		}
+		if !progress {
+			return nil
+		}
+	}
+	return order
+}
+
+// graphCycles finds the groups of projects that consume each other's interfaces, directly or not, with Tarjan's
+// algorithm. A project that consumes its own interface is a cycle too.
+func graphCycles(g ProjectGraph) [][]string {
+	producers := g.producers()
+	index := make(map[string]int)
+	lowLink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	cycles := [][]string{}
+	var visit func(project string)
+	visit = func(project string) {
+		index[project] = len(index)
+		lowLink[project] = index[project]
+		stack = append(stack, project)
+		onStack[project] = true
+		for _, producer := range producers[project] {
+			if _, visited := index[producer]; !visited {
+				visit(producer)
+				lowLink[project] = min(lowLink[project], lowLink[producer])
+			} else if onStack[producer] {
+				lowLink[project] = min(lowLink[project], index[producer])
+			}
+		}
+		if lowLink[project] != index[project] {
+			return
+		}
+		var component []string
+		for {
+			member := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[member] = false
+			component = append(component, member)
+			if member == project {
+				break
+			}
+		}
+		if len(component) > 1 || hasString(producers[project], project) {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+	for _, project := range g.Projects {
+		if _, visited := index[project]; !visited {
+			visit(project)
+		}
+	}
+	return cycles
+}
+
+func hasString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func renderGraph(g ProjectGraph, format string) (string, error) {
+	var b strings.Builder
+	switch format {
+	case "dot":
+		fmt.Fprintln(&b, "digraph projects {")
+		fmt.Fprintln(&b, "  rankdir = LR;")
+		for _, project := range g.Projects {
+			fmt.Fprintf(&b, "  %q;\n", project)
+		}
+		for _, edge := range g.Edges {
+			fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", edge.Consumer, edge.Producer, filepath.Base(edge.Interface))
+		}
+		fmt.Fprintln(&b, "}")
+	case "mermaid":
+		ids := make(map[string]string)
+		fmt.Fprintln(&b, "graph LR")
+		for i, project := range g.Projects {
+			ids[project] = fmt.Sprintf("p%d", i)
+			fmt.Fprintf(&b, "  %s[%q]\n", ids[project], project)
+		}
+		for _, edge := range g.Edges {
+			fmt.Fprintf(&b, "  %s -->|%s| %s\n", ids[edge.Consumer], filepath.Base(edge.Interface), ids[edge.Producer])
+		}
+	case "json":
+		content, err := json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		b.Write(content)
+		b.WriteString("\n")
+	default:
+		return "", fmt.Errorf("unsupported graph format %q, expected one of dot, mermaid, json", format)
+	}
+	return b.String(), nil
+}
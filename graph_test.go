@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildProjectGraph(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/repo/network/main.tf", []byte("# @public\noutput \"vpc_id\" {\n  value = aws_vpc.main.id\n}\n"), 0644)
+	afero.WriteFile(fs, "/repo/dns/main.tf", []byte(`module "network" {
+  source = "../network/interface"
+}
+
+# @internal
+output "zone_id" {
+  value = aws_route53_zone.main.zone_id
+}
+`), 0644)
+	afero.WriteFile(fs, "/repo/app/main.tf", []byte(`module "dns" {
+  source = "git::https://example.com/infra.git//dns/interface-internal?ref=main"
+}
+
+module "network" {
+  source = "../network/interface"
+}
+`), 0644)
+	projects := []ProjectConfig{{Path: "app"}, {Path: "dns"}, {Path: "network"}}
+
+	graph, err := buildProjectGraph(fs, "/repo", projects)
+	assert.Nil(t, err)
+	assert.Equal(t, []GraphEdge{
+		{Consumer: "app", Producer: "dns", Interface: "dns/interface-internal", File: "app/main.tf"},
+		{Consumer: "app", Producer: "network", Interface: "network/interface", File: "app/main.tf"},
+		{Consumer: "dns", Producer: "network", Interface: "network/interface", File: "dns/main.tf"},
+	}, graph.Edges)
+	assert.Equal(t, []string{"network", "dns", "app"}, graph.Order)
+	assert.Empty(t, graph.Cycles)
+
+	dot, err := renderGraph(graph, "dot")
+	assert.Nil(t, err)
+	assert.Contains(t, dot, "  \"app\" -> \"dns\" [label=\"interface-internal\"];\n")
+	mermaid, err := renderGraph(graph, "mermaid")
+	assert.Nil(t, err)
+	assert.Contains(t, mermaid, "graph LR\n  p0[\"app\"]\n")
+	assert.Contains(t, mermaid, "  p1 -->|interface| p2\n")
+	_, err = renderGraph(graph, "svg")
+	assert.EqualError(t, err, `unsupported graph format "svg", expected one of dot, mermaid, json`)
+
+	afero.WriteFile(fs, "/repo/network/consumers.tf", []byte("module \"app\" {\n  source = \"../app/interface\"\n}\n"), 0644)
+	afero.WriteFile(fs, "/repo/app/outputs.tf", []byte("# @public\noutput \"url\" {\n  value = aws_lb.main.dns_name\n}\n"), 0644)
+	graph, err = buildProjectGraph(fs, "/repo", projects)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"app", "dns", "network"}}, graph.Cycles)
+	assert.Empty(t, graph.Order)
+}
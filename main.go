@@ -2,31 +2,71 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
-	"flag"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
-	"gopkg.in/yaml.v2"
+	"github.com/spf13/afero"
 )
 
-type Project struct {
-	Path                string `yaml:"path"`
-	GeneratedFolderName string `yaml:"generatedFolderName"`
-	GeneratedFolderPath string `yaml:"generatedFolderPath"`
+type ProjectConfig struct {
+	Path                string            `yaml:"path"`
+	GeneratedFolderName string            `yaml:"generatedFolderName"`
+	GeneratedFolderPath string            `yaml:"generatedFolderPath"`
+	Discover            bool              `yaml:"discover"`
+	Exclude             []string          `yaml:"exclude"`
+	Command             string            `yaml:"command"`
+	Shell               string            `yaml:"shell"`
+	Env                 map[string]string `yaml:"env"`
+	VarFiles            []string          `yaml:"varFiles"`
+	Workspace           string            `yaml:"workspace"`
+	Timeout             time.Duration     `yaml:"timeout"`
+	Terragrunt          string            `yaml:"terragrunt"`
+	// AudienceFolders overrides the folder name of an audience's interface module, e.g. internal: internal-interface.
+	AudienceFolders map[string]string `yaml:"audienceFolders"`
+	// Owner is the team that owns the project, recorded in the manifests and shown in the catalog.
+	Owner string `yaml:"owner"`
+	// Unresolved is what to do with a lookup value that is not in state: fail (the default), skip the outputs that
+	// need it, or variable to read it from a required variable of the interface module.
+	Unresolved string `yaml:"unresolved"`
 }
 
 type Config struct {
-	Shell    string    `yaml:"shell"`
-	Projects []Project `yaml:"projects"`
-	Command  string    `yaml:"command"`
-	Verbose  bool      `yaml:"verbose"`
+	Shell       string            `yaml:"shell"`
+	Projects    []ProjectConfig   `yaml:"projects"`
+	Exclude     []string          `yaml:"exclude"`
+	Command     string            `yaml:"command"`
+	Env         map[string]string `yaml:"env"`
+	VarFiles    []string          `yaml:"varFiles"`
+	Workspace   string            `yaml:"workspace"`
+	Timeout     time.Duration     `yaml:"timeout"`
+	Parallelism int               `yaml:"parallelism"`
+	Verbose     bool              `yaml:"verbose"`
+	Terragrunt  string            `yaml:"terragrunt"`
+	// Consumers are the Terraform roots that the usage command scans for uses of the interfaces.
+	Consumers []string `yaml:"consumers"`
+}
+
+// ProjectSettings are the settings a project is run with: its own overrides merged over the global ones.
+type ProjectSettings struct {
+	Shell     string
+	Command   string
+	Env       map[string]string
+	VarFiles  []string
+	Workspace string
+	Timeout   time.Duration
+	// Terragrunt is the command that Terragrunt units are run with instead of Command.
+	Terragrunt string
 }
 
 type TerraformState struct {
@@ -41,6 +81,14 @@ type TerraformState struct {
 			} `json:"outputs"`
 		} `json:"root_module"`
 	} `json:"values"`
+	// Outputs are the root module's outputs with their types, read from values.outputs by fetchTerraformState.
+	Outputs map[string]StateOutput `json:"-"`
+}
+
+type StateOutput struct {
+	Value     interface{} `json:"value"`
+	Type      interface{} `json:"type"`
+	Sensitive bool        `json:"sensitive"`
 }
 
 type ProviderSchema struct {
@@ -58,6 +106,13 @@ type ResourceSchema struct {
 
 type ResourceBlock struct {
 	Attributes map[string]Attribute `json:"attributes"`
+	// BlockTypes are the nested blocks, such as root_block_device, which an output can read as a whole too.
+	BlockTypes map[string]NestedBlock `json:"block_types"`
+}
+
+type NestedBlock struct {
+	NestingMode string        `json:"nesting_mode"`
+	Block       ResourceBlock `json:"block"`
 }
 
 type Attribute struct {
@@ -66,19 +121,31 @@ type Attribute struct {
 	Optional    bool        `json:"optional"`
 	Computed    bool        `json:"computed"`
 	Required    bool        `json:"required"`
+	Sensitive   bool        `json:"sensitive"`
 }
 
 type AnnotatedOutput struct {
-	File      string
-	Line      int
-	Output    string
-	Reference string
-	Provider  string
+	File       string
+	Line       int
+	Output     string
+	Reference  string
+	Provider   string
+	Annotation Annotation
+}
+
+func findAnnotatedOutputs(fs afero.Fs, path string, verbose bool) []AnnotatedOutput {
+	annotatedOutputs, err := scanAnnotatedOutputs(fs, path, verbose)
+	if err != nil {
+		log.Fatalf("Failed to walk through the Terraform project path: %v", err)
+	}
+	return annotatedOutputs
 }
 
-func findAnnotatedOutputs(path string, verbose bool) []AnnotatedOutput {
+// scanAnnotatedOutputs finds the outputs annotated with a visibility tag in the .tf files below path. An invalid annotation is
+// returned as an *AnnotationError.
+func scanAnnotatedOutputs(fs afero.Fs, path string, verbose bool) ([]AnnotatedOutput, error) {
 	var annotatedOutputs []AnnotatedOutput
-	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+	err := afero.Walk(fs, path, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -86,13 +153,14 @@ func findAnnotatedOutputs(path string, verbose bool) []AnnotatedOutput {
 			if verbose {
 				log.Printf("Processing file: %s", path)
 			}
-			file, err := os.Open(path)
+			file, err := fs.Open(path)
 			if err != nil {
 				return err
 			}
 			defer file.Close()
 			scanner := bufio.NewScanner(file)
 			inAnnotation := false
+			var annotation Annotation
 			outputBlock := ""
 			lineNumber := 0
 			for scanner.Scan() {
@@ -102,14 +170,22 @@ func findAnnotatedOutputs(path string, verbose bool) []AnnotatedOutput {
 					log.Printf("Reading line: %s", line)
 				}
 				if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
-					if strings.Contains(line, "@public") {
+					parsed, found, err := parseAnnotation(line)
+					if err != nil {
+						return &AnnotationError{File: path, Line: lineNumber, Message: err.Error()}
+					}
+					if found {
+						if err := annotation.merge(parsed); err != nil {
+							return &AnnotationError{File: path, Line: lineNumber, Message: err.Error()}
+						}
 						inAnnotation = true
 						if verbose {
-							log.Println("@public annotation found")
+							log.Printf("Annotation found for %s", strings.Join(parsed.Audiences, ", "))
 						}
 					}
 				} else if inAnnotation {
 					if strings.HasPrefix(line, "output") {
+						outputLine := lineNumber
 						outputBlock = line
 						for scanner.Scan() {
 							lineNumber++
@@ -120,26 +196,70 @@ func findAnnotatedOutputs(path string, verbose bool) []AnnotatedOutput {
 							}
 						}
 						outputInfo, reference := extractOutputInfo(outputBlock)
+						if annotation.Strategy == "" {
+							annotation.Strategy = strategyDataSource
+						}
+						if len(annotation.Audiences) == 0 {
+							return &AnnotationError{File: path, Line: outputLine, Message: fmt.Sprintf(
+								"output is marked @%s but has no visibility tag such as @public", annotation.Stability)}
+						}
 						if outputInfo != "" {
 							annotatedOutputs = append(annotatedOutputs, AnnotatedOutput{
-								File:      path,
-								Line:      lineNumber,
-								Output:    outputInfo,
-								Reference: reference,
+								File:       path,
+								Line:       outputLine,
+								Output:     outputInfo,
+								Reference:  reference,
+								Annotation: annotation,
 							})
 						}
 						outputBlock = ""
 						inAnnotation = false
+						annotation = Annotation{}
 					}
 				}
 			}
 		}
 		return nil
 	})
+	return annotatedOutputs, err
+}
+
+// projectOutputs returns the annotated outputs of a project with file paths relative to it. For a Terragrunt unit, the
+// outputs are read from the module it deploys.
+func projectOutputs(fs afero.Fs, projectPath string, verbose bool) ([]AnnotatedOutput, error) {
+	moduleDir, err := projectModuleDir(fs, projectPath, verbose)
 	if err != nil {
-		log.Fatalf("Failed to walk through the Terraform project path: %v", err)
+		return nil, err
 	}
-	return annotatedOutputs
+	outputs, err := scanAnnotatedOutputs(fs, moduleDir, verbose)
+	for i := range outputs {
+		if relativePath, relErr := filepath.Rel(projectPath, outputs[i].File); relErr == nil {
+			outputs[i].File = relativePath
+		}
+	}
+	var annotationErr *AnnotationError
+	if errors.As(err, &annotationErr) {
+		if relativePath, relErr := filepath.Rel(projectPath, annotationErr.File); relErr == nil {
+			annotationErr.File = relativePath
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return outputs, checkExportedNames(outputs)
+}
+
+// projectModuleDir is the directory with the .tf files of a project: the project itself, or for a Terragrunt unit, the
+// module it deploys.
+func projectModuleDir(fs afero.Fs, projectPath string, verbose bool) (string, error) {
+	if !isTerragruntUnit(fs, projectPath) {
+		return projectPath, nil
+	}
+	moduleDir, err := terragruntModuleDir(fs, projectPath)
+	if err == nil && verbose {
+		log.Printf("Reading Terragrunt unit %s from %s", projectPath, moduleDir)
+	}
+	return moduleDir, err
 }
 
 func extractOutputInfo(outputBlock string) (string, string) {
@@ -158,34 +278,31 @@ func extractOutputInfo(outputBlock string) (string, string) {
 	return "", ""
 }
 
-func fetchTerraformState(shell string, command string, projectPath string, verbose bool) (TerraformState, error) {
-	cmdStr := fmt.Sprintf(`%s show -json`, command)
-	if verbose {
-		log.Printf("Running command: %s -c \"%s\"", shell, cmdStr)
-	}
-	cmd := exec.Command(shell, "-c", cmdStr)
-	cmd.Dir = projectPath
-	output, err := cmd.CombinedOutput()
+func fetchTerraformState(ctx context.Context, runner Runner, settings ProjectSettings, projectPath string) (TerraformState, error) {
+	output, err := runner.Run(ctx, settings, projectPath, "show", "-json")
 	if err != nil {
-		return TerraformState{}, fmt.Errorf("failed to run command: %v, output: %s", err, output)
+		return TerraformState{}, err
 	}
 	var state TerraformState
 	if err := json.Unmarshal(output, &state); err != nil {
 		return TerraformState{}, fmt.Errorf("failed to parse JSON output: %v", err)
 	}
+	var outputs struct {
+		Values struct {
+			Outputs map[string]StateOutput `json:"outputs"`
+		} `json:"values"`
+	}
+	if err := json.Unmarshal(output, &outputs); err != nil {
+		return TerraformState{}, fmt.Errorf("failed to parse JSON output: %v", err)
+	}
+	state.Outputs = outputs.Values.Outputs
 	return state, nil
 }
 
-func fetchProviderSchema(shell string, command string, projectPath string, verbose bool) (ProviderSchema, error) {
-	cmdStr := fmt.Sprintf(`%s providers schema -json`, command)
-	if verbose {
-		log.Printf("Running command: %s -c \"%s\"", shell, cmdStr)
-	}
-	cmd := exec.Command(shell, "-c", cmdStr)
-	cmd.Dir = projectPath
-	output, err := cmd.CombinedOutput()
+func fetchProviderSchema(ctx context.Context, runner Runner, settings ProjectSettings, projectPath string) (ProviderSchema, error) {
+	output, err := runner.Run(ctx, settings, projectPath, "providers", "schema", "-json")
 	if err != nil {
-		return ProviderSchema{}, fmt.Errorf("failed to run command: %v, output: %s", err, output)
+		return ProviderSchema{}, err
 	}
 	var schema ProviderSchema
 	if err := json.Unmarshal(output, &schema); err != nil {
@@ -194,6 +311,45 @@ func fetchProviderSchema(shell string, command string, projectPath string, verbo
 	return schema, nil
 }
 
+func sortedKeys(values map[string]string) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func projectSettings(project ProjectConfig, defaults ProjectSettings) ProjectSettings {
+	settings := defaults
+	if project.Shell != "" {
+		settings.Shell = project.Shell
+	}
+	if project.Command != "" {
+		settings.Command = project.Command
+	}
+	settings.Env = make(map[string]string)
+	for key, value := range defaults.Env {
+		settings.Env[key] = value
+	}
+	for key, value := range project.Env {
+		settings.Env[key] = value
+	}
+	if len(project.VarFiles) > 0 {
+		settings.VarFiles = project.VarFiles
+	}
+	if project.Workspace != "" {
+		settings.Workspace = project.Workspace
+	}
+	if project.Timeout != 0 {
+		settings.Timeout = project.Timeout
+	}
+	if project.Terragrunt != "" {
+		settings.Terragrunt = project.Terragrunt
+	}
+	return settings
+}
+
 func findMatchingDataResource(reference string, schema ProviderSchema) (bool, []string) {
 	parts := strings.Split(reference, ".")
 	resourceType := parts[0]
@@ -205,12 +361,72 @@ func findMatchingDataResource(reference string, schema ProviderSchema) (bool, []
 					requiredAttributes = append(requiredAttributes, attributeName)
 				}
 			}
+			sort.Strings(requiredAttributes)
 			return true, requiredAttributes
 		}
 	}
 	return false, nil
 }
 
+func dataSourceProvider(dataSourceType string, schema ProviderSchema) string {
+	for providerSource, providerSchema := range schema.ProviderSchemas {
+		if _, exists := providerSchema.DataSourceSchemas[dataSourceType]; exists {
+			return providerSource
+		}
+	}
+	return ""
+}
+
+// attributeRenames are the attributes that a data source exposes under another name than the resource of the same
+// type, by resource type and resource attribute.
+var attributeRenames = map[string]map[string]string{
+	"aws_db_instance": {"identifier": "db_instance_identifier", "arn": "db_instance_arn"},
+	"aws_ecs_cluster": {"name": "cluster_name"},
+	"aws_ecs_service": {"name": "service_name"},
+}
+
+// dataSourceAttributeName is the name of the data source attribute that holds a resource attribute.
+func dataSourceAttributeName(resourceType string, attribute string) string {
+	if renamed, exists := attributeRenames[resourceType][attribute]; exists {
+		return renamed
+	}
+	return attribute
+}
+
+// resourceAttributeName is the name of the resource attribute that a data source attribute is looked up with.
+func resourceAttributeName(resourceType string, attribute string) string {
+	for resourceAttribute, renamed := range attributeRenames[resourceType] {
+		if renamed == attribute {
+			return resourceAttribute
+		}
+	}
+	return attribute
+}
+
+// hasDataSourceAttribute reports whether a data source exposes an attribute or a nested block of that name.
+func hasDataSourceAttribute(dataSourceType string, attribute string, schema ProviderSchema) bool {
+	for _, providerSchema := range schema.ProviderSchemas {
+		if dataSourceSchema, exists := providerSchema.DataSourceSchemas[dataSourceType]; exists {
+			_, isAttribute := dataSourceSchema.Block.Attributes[attribute]
+			_, isBlock := dataSourceSchema.Block.BlockTypes[attribute]
+			return isAttribute || isBlock
+		}
+	}
+	return false
+}
+
+func isSensitiveAttribute(resourceType string, attribute string, schema ProviderSchema) bool {
+	for _, providerSchema := range schema.ProviderSchemas {
+		if resourceSchema, exists := providerSchema.ResourceSchemas[resourceType]; exists && resourceSchema.Block.Attributes[attribute].Sensitive {
+			return true
+		}
+		if dataSourceSchema, exists := providerSchema.DataSourceSchemas[resourceType]; exists && dataSourceSchema.Block.Attributes[attribute].Sensitive {
+			return true
+		}
+	}
+	return false
+}
+
 func extractAttributeValue(reference string, attribute string, state TerraformState) (interface{}, bool) {
 	for _, res := range state.Values.RootModule.Resources {
 		if res.Address == reference {
@@ -231,15 +447,10 @@ func getResourceState(reference string, state TerraformState) (map[string]interf
 	return nil, false
 }
 
-func createInterfaceDirectory(basePath string, projectPath string, folderName string, folderPath string, verbose bool) string {
-	if folderPath == "" {
-		folderPath = projectPath
-	}
-	interfaceDir := filepath.Join(basePath, folderPath, folderName)
-	if _, err := os.Stat(interfaceDir); os.IsNotExist(err) {
-		err := os.MkdirAll(interfaceDir, 0755)
-		if err != nil {
-			log.Fatalf("Failed to create directory %s: %v", interfaceDir, err)
+func createInterfaceDirectory(fs afero.Fs, interfaceDir string, verbose bool) error {
+	if _, err := fs.Stat(interfaceDir); os.IsNotExist(err) {
+		if err := fs.MkdirAll(interfaceDir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %v", interfaceDir, err)
 		}
 		if verbose {
 			log.Printf("Created directory: %s", interfaceDir)
@@ -247,75 +458,87 @@ func createInterfaceDirectory(basePath string, projectPath string, folderName st
 	} else if verbose {
 		log.Printf("Directory already exists: %s", interfaceDir)
 	}
-	return interfaceDir
+	return nil
 }
 
-func createTerraformFile(interfaceDir string, outputs []AnnotatedOutput, state TerraformState, schema ProviderSchema, verbose bool) {
-	filePath := filepath.Join(interfaceDir, "generated_data.tf")
-	file, err := os.Create(filePath)
-	if err != nil {
-		log.Fatalf("Failed to create Terraform file %s: %v", filePath, err)
+// interfaceDirectory is where the interface module of an audience is generated. The public module goes in the
+// generated folder and every other audience's module in a sibling folder named after it, e.g. interface-internal.
+func interfaceDirectory(basePath string, project ProjectConfig, audience string) string {
+	folderName := project.GeneratedFolderName
+	if folderName == "" {
+		folderName = "interface"
+	}
+	if audience != audiencePublic {
+		folderName += "-" + audience
+	}
+	if name, exists := project.AudienceFolders[audience]; exists {
+		folderName = name
+	}
+	folderPath := project.GeneratedFolderPath
+	if folderPath == "" {
+		folderPath = project.Path
 	}
-	defer file.Close()
-	writer := bufio.NewWriter(file)
+	return filepath.Join(basePath, folderPath, folderName)
+}
+
+func planDataSources(outputs []AnnotatedOutput, state TerraformState, schema ProviderSchema, verbose bool) ([]DataSourcePlan, []string) {
+	var dataSources []DataSourcePlan
+	var warnings []string
 	seenResources := make(map[string]bool)
 	for _, output := range outputs {
 		parts := strings.Split(output.Reference, ".")
-		if len(parts) != 3 {
-			log.Fatalf("Invalid reference format: %s", output.Reference)
-		}
-		resourceType := parts[0]
-		resourceName := parts[1]
-		uniqueKey := fmt.Sprintf("%s.%s", resourceType, resourceName)
-		if _, seen := seenResources[uniqueKey]; !seen {
-			seenResources[uniqueKey] = true
-			hasMatchingDataResource, dataResourceRequiredAttributes := findMatchingDataResource(fmt.Sprintf("%s.%s", resourceType, resourceName), schema)
-			if hasMatchingDataResource {
-				resourceState, exists := getResourceState(fmt.Sprintf("%s.%s", resourceType, resourceName), state)
-				if exists {
-					fmt.Printf("\033[32mMatching resource: %s.%s\033[0m\n", resourceType, resourceName)
-					if verbose {
-						fmt.Printf("\033[32mResource State for %s.%s:\n%+v\033[0m\n", resourceType, resourceName, resourceState)
-					}
-				} else {
-					log.Printf("Resource %s.%s not found in state\n", resourceType, resourceName)
-				}
-				fmt.Printf("\033[32mData source: %s.%s\033[0m\n", resourceType, resourceName)
-				fmt.Fprintf(writer, "data \"%s\" \"%s\" {\n", resourceType, resourceName)
-				for _, attr := range dataResourceRequiredAttributes {
-					if value, exists := extractAttributeValue(fmt.Sprintf("%s.%s", resourceType, resourceName), attr, state); exists {
-						fmt.Fprintf(writer, "  %s = \"%v\"\n", attr, value)
-						fmt.Printf("\033[32mRequired attribute: %s = %v\033[0m\n", attr, value)
-					} else {
-						fmt.Fprintf(writer, "  %s = \"\"\n", attr) // Default value if not found in state
-						fmt.Printf("\033[32mRequired attribute: %s = <nil>\033[0m\n", attr)
-					}
-				}
-				fmt.Fprintf(writer, "}\n\n")
-			}
+		resourceReference := strings.Join(parts[:2], ".")
+		if seenResources[resourceReference] {
+			continue
 		}
+		seenResources[resourceReference] = true
+		hasMatchingDataResource, dataResourceRequiredAttributes := findMatchingDataResource(resourceReference, schema)
+		if !hasMatchingDataResource {
+			continue
+		}
+		resourceState, exists := getResourceState(resourceReference, state)
+		if !exists {
+			warnings = append(warnings, fmt.Sprintf("Resource %s not found in state", resourceReference))
+		} else if verbose {
+			log.Printf("Resource state for %s: %+v", resourceReference, resourceState)
+		}
+		dataSource := DataSourcePlan{Type: parts[0], Name: parts[1], Provider: dataSourceProvider(parts[0], schema)}
+		for _, attr := range dataResourceRequiredAttributes {
+			value, found := extractAttributeValue(resourceReference, resourceAttributeName(parts[0], attr), state)
+			dataSource.Lookups = append(dataSource.Lookups, LookupValue{
+				Attribute: attr,
+				Value:     value,
+				Found:     found,
+				Sensitive: isSensitiveAttribute(parts[0], attr, schema),
+			})
+		}
+		dataSources = append(dataSources, dataSource)
 	}
-	writer.Flush()
-	if verbose {
-		log.Printf("Created Terraform file: %s", filePath)
-	}
+	return dataSources, warnings
 }
 
-func createProviderFile(interfaceDir string, outputs []AnnotatedOutput, schema ProviderSchema) {
-	filePath := filepath.Join(interfaceDir, "generated_providers.tf")
-	file, err := os.Create(filePath)
-	if err != nil {
-		log.Fatalf("Failed to create Terraform file %s: %v", filePath, err)
+func renderDataFile(dataSources []DataSourcePlan) string {
+	var b strings.Builder
+	for _, dataSource := range dataSources {
+		fmt.Fprintf(&b, "data \"%s\" \"%s\" {\n", dataSource.Type, dataSource.Name)
+		for _, lookup := range dataSource.Lookups {
+			if lookup.Found {
+				fmt.Fprintf(&b, "  %s = \"%v\"\n", lookup.Attribute, lookup.Value)
+			} else {
+				// Only the variable policy keeps lookup values that were not found in state.
+				fmt.Fprintf(&b, "  %s = var.%s\n", lookup.Attribute, lookupVariable(dataSource, lookup))
+			}
+		}
+		fmt.Fprintf(&b, "}\n\n")
 	}
-	defer file.Close()
-	writer := bufio.NewWriter(file)
+	return b.String()
+}
+
+func renderProviderFile(outputs []AnnotatedOutput, schema ProviderSchema) string {
+	var b strings.Builder
 	providers := make(map[string]string)
 	for _, output := range outputs {
-		parts := strings.Split(output.Reference, ".")
-		if len(parts) != 3 {
-			log.Fatalf("Invalid reference format: %s", output.Reference)
-		}
-		resourceType := parts[0]
+		resourceType := strings.Split(output.Reference, ".")[0]
 		for providerSource, providerSchema := range schema.ProviderSchemas {
 			if _, exists := providerSchema.ResourceSchemas[resourceType]; exists {
 				providerName := strings.Split(providerSource, "/")[2]
@@ -323,145 +546,160 @@ func createProviderFile(interfaceDir string, outputs []AnnotatedOutput, schema P
 			}
 		}
 	}
-	fmt.Fprintln(writer, "terraform {")
-	fmt.Fprintln(writer, "  required_providers {")
-	for provider, source := range providers {
-		fmt.Fprintf(writer, "    %s = {\n", provider)
-		fmt.Fprintf(writer, "      source = \"%s\"\n", source)
-		fmt.Fprintln(writer, "    }")
+	var providerNames []string
+	for provider := range providers {
+		providerNames = append(providerNames, provider)
+	}
+	sort.Strings(providerNames)
+	fmt.Fprintln(&b, "terraform {")
+	fmt.Fprintln(&b, "  required_providers {")
+	for _, provider := range providerNames {
+		fmt.Fprintf(&b, "    %s = {\n", provider)
+		fmt.Fprintf(&b, "      source = \"%s\"\n", providers[provider])
+		fmt.Fprintln(&b, "    }")
+	}
+	fmt.Fprintln(&b, "  }")
+	fmt.Fprintln(&b, "}")
+	return b.String()
+}
+
+func renderOutputsFile(outputs []AnnotatedOutput) string {
+	return renderOutputs(outputs, outputValue)
+}
+
+// renderOutputs renders an output block for each output, with value giving the expression it is read with.
+func renderOutputs(outputs []AnnotatedOutput, value func(AnnotatedOutput) string) string {
+	var b strings.Builder
+	checks := false
+	for _, output := range outputs {
+		if output.Annotation.Since != "" {
+			fmt.Fprintf(&b, "# Available since %s\n", output.Annotation.Since)
+		}
+		if comment := stabilityComment(output.Annotation); comment != "" {
+			fmt.Fprintln(&b, comment)
+		}
+		fmt.Fprintf(&b, "output \"%s\" {\n", output.ExportedName())
+		if description := stabilityDescription(output.Annotation); description != "" {
+			fmt.Fprintf(&b, "  description = %s\n", hclString(description))
+		}
+		expression := value(output)
+		fmt.Fprintf(&b, "  value = %s\n", expression)
+		fmt.Fprintf(&b, "}\n\n")
+		// An output read from a submodule gets its deprecation check there.
+		if output.Annotation.Stability == stabilityDeprecated && !strings.HasPrefix(expression, "module.") {
+			b.WriteString(renderDeprecationCheck(output))
+			checks = true
+		}
+	}
+	if checks {
+		return checkVersionConstraint + b.String()
 	}
-	fmt.Fprintln(writer, "  }")
-	fmt.Fprintln(writer, "}")
-	writer.Flush()
+	return b.String()
 }
 
-func createOutputsFile(interfaceDir string, outputs []AnnotatedOutput, verbose bool) {
-	filePath := filepath.Join(interfaceDir, "generated_outputs.tf")
-	file, err := os.Create(filePath)
-	if err != nil {
-		log.Fatalf("Failed to create Terraform file %s: %v", filePath, err)
+// outputValue is the expression the interface module reads an output's value with.
+func outputValue(output AnnotatedOutput) string {
+	if output.Annotation.Strategy == strategyRemoteState {
+		return "data.terraform_remote_state.this.outputs." + output.Output
 	}
-	defer file.Close()
-	writer := bufio.NewWriter(file)
-	for _, output := range outputs {
-		parts := strings.Split(output.Reference, ".")
-		if len(parts) != 3 {
-			log.Fatalf("Invalid reference format: %s", output.Reference)
+	parts := strings.Split(output.Reference, ".")
+	return fmt.Sprintf("data.%s.%s.%s", parts[0], parts[1], dataSourceAttributeName(parts[0], parts[2]))
+}
+
+func skipReason(output AnnotatedOutput, schema ProviderSchema) string {
+	if output.Annotation.Strategy == strategyRemoteState {
+		return ""
+	}
+	parts := strings.Split(output.Reference, ".")
+	if len(parts) != 3 {
+		return fmt.Sprintf("value %q is not a <type>.<name>.<attribute> resource reference", output.Reference)
+	}
+	if hasMatchingDataResource, _ := findMatchingDataResource(output.Reference, schema); !hasMatchingDataResource {
+		return fmt.Sprintf("no data source matches resource type %s", parts[0])
+	}
+	if attribute := dataSourceAttributeName(parts[0], parts[2]); !hasDataSourceAttribute(parts[0], attribute, schema) {
+		if attribute != parts[2] {
+			return fmt.Sprintf("data source %s has no attribute %s (attribute %s of the resource)", parts[0], attribute, parts[2])
 		}
-		resourceType := parts[0]
-		resourceName := parts[1]
-		fmt.Fprintf(writer, "output \"%s\" {\n", output.Output)
-		fmt.Fprintf(writer, "  value = data.%s.%s.%s\n", resourceType, resourceName, parts[2])
-		fmt.Fprintf(writer, "}\n\n")
+		return fmt.Sprintf("data source %s has no attribute %s", parts[0], attribute)
 	}
-	writer.Flush()
-	if verbose {
-		log.Printf("Created Terraform file: %s", filePath)
+	return ""
+}
+
+func filterValidOutputs(outputs []AnnotatedOutput, schema ProviderSchema, state TerraformState, verbose bool) []AnnotatedOutput {
+	var validOutputs []AnnotatedOutput
+	for _, output := range outputs {
+		if reason := skipReason(output, schema); reason != "" {
+			fmt.Printf("\033[31mSkipping output %s (%s:%d): %s\033[0m\n", output.Output, output.File, output.Line, reason)
+			continue
+		}
+		validOutputs = append(validOutputs, output)
 	}
+	return validOutputs
 }
 
-func main() {
-	shellFlag := flag.String("shell", "", "Shell to use for executing commands")
-	projectPathFlag := flag.String("project-path", "", "Path to the Terraform project")
-	commandFlag := flag.String("command", "terraform", "Command to use to call terraform/tofu")
-	verboseFlag := flag.Bool("verbose", false, "Enable verbose output")
-	flag.Parse()
-	config := Config{}
-	configFile, err := ioutil.ReadFile("config.yaml")
-	if err == nil {
-		fmt.Printf("Config file found and read successfully.\n")
-		if err := yaml.Unmarshal(configFile, &config); err != nil {
-			fmt.Printf("\033[31mFailed to parse config file: %v\033[0m\n", err)
-		}
-	} else {
-		fmt.Printf("\033[31mConfig file not found or failed to read: %v\033[0m\n", err)
-	}
-	if *verboseFlag {
-		log.Printf("Config file content: %s", string(configFile))
-		log.Printf("Parsed config: %+v", config)
-	}
-	shell := "bash"
-	if *shellFlag != "" {
-		shell = *shellFlag
-	} else if config.Shell != "" {
-		shell = config.Shell
-	}
-	shellPath, err := exec.LookPath(shell)
-	if err != nil {
-		log.Fatalf("Shell not found or not executable: %s", shell)
-	}
-	if *verboseFlag {
-		log.Printf("Using shell path: %s", shellPath)
-	}
-	projects := []Project{{Path: "."}}
-	if *projectPathFlag != "" {
-		projects = []Project{{Path: *projectPathFlag}}
-	} else if len(config.Projects) > 0 {
-		projects = config.Projects
-	}
-	command := *commandFlag
-	if config.Command != "" {
-		command = config.Command
-	}
-	verbose := *verboseFlag || config.Verbose
-	fmt.Printf("Using shell: %s\n", shell)
-	fmt.Printf("Projects:\n")
-	for _, project := range projects {
-		fmt.Printf("  %s\n", project.Path)
-	}
-	fmt.Printf("Using command: %s\n", command)
-	fmt.Printf("Verbose output: %v\n", verbose)
-	currentDir, err := os.Getwd()
-	if err != nil {
-		log.Fatalf("Failed to get current directory: %v", err)
+func (opts options) print(status io.Writer) {
+	if opts.Shell != "" {
+		fmt.Fprintf(status, "Using shell: %s\n", opts.Shell)
+	}
+	fmt.Fprintf(status, "Projects:\n")
+	for _, project := range opts.Projects {
+		fmt.Fprintf(status, "  %s\n", project.Path)
 	}
-	envPath := os.Getenv("PATH")
-	if verbose {
-		log.Printf("Environment PATH: %s", envPath)
+	fmt.Fprintf(status, "Using command: %s\n", opts.Command)
+	fmt.Fprintf(status, "Parallelism: %d\n", opts.Parallelism)
+	fmt.Fprintf(status, "Verbose output: %v\n", opts.Verbose)
+	if opts.Verbose {
+		log.Printf("Environment PATH: %s", os.Getenv("PATH"))
 	}
-	for _, project := range projects {
-		fullPath := filepath.Join(currentDir, project.Path)
-		fmt.Printf("\033[1;33mChanging directory to Terraform project: %s\033[0m\n", fullPath)
-		if err := os.Chdir(fullPath); err != nil {
-			log.Fatalf("Failed to change directory to Terraform project: %v", err)
+}
+
+func (opts options) checkExecutables() error {
+	if _, replaying := opts.Runner.(FixtureRunner); replaying {
+		return nil
+	}
+	fs := afero.NewOsFs()
+	for _, project := range opts.Projects {
+		settings := opts.settingsFor(project)
+		if isTerragruntUnit(fs, filepath.Join(opts.CurrentDir, project.Path)) {
+			settings = terragruntSettings(settings)
 		}
-		state, err := fetchTerraformState(shell, command, fullPath, verbose)
-		if err != nil {
-			log.Fatalf("Failed to fetch Terraform state: %v", err)
+		executable := settings.Shell
+		if executable == "" {
+			fields := strings.Fields(settings.Command)
+			if len(fields) == 0 {
+				return fmt.Errorf("no command configured for project %s", project.Path)
+			}
+			executable = fields[0]
 		}
-		schema, err := fetchProviderSchema(shell, command, fullPath, verbose)
+		executablePath, err := exec.LookPath(executable)
 		if err != nil {
-			log.Fatalf("Failed to fetch provider schema: %v", err)
-		}
-		annotatedOutputs := findAnnotatedOutputs(".", verbose)
-		if len(annotatedOutputs) == 0 {
-			fmt.Println("No Annotated Outputs")
-		} else {
-			fmt.Println("Annotated Outputs:")
-			var validOutputs []AnnotatedOutput
-			for _, output := range annotatedOutputs {
-				parts := strings.Split(output.Reference, ".")
-				resourceReference := strings.Join(parts[:2], ".")
-				hasMatchingDataResource, _ := findMatchingDataResource(resourceReference, schema)
-				if hasMatchingDataResource {
-					validOutputs = append(validOutputs, output)
-				} else {
-					fmt.Printf("\033[31mAnnotated output %s at line %d in file %s does not have a matching data resource!\033[0m\n", output.Output, output.Line, output.File)
-				}
-			}
-			if len(validOutputs) > 0 {
-				folderName := project.GeneratedFolderName
-				if folderName == "" {
-					folderName = "interface"
-				}
-				interfaceDir := createInterfaceDirectory(currentDir, project.Path, folderName, project.GeneratedFolderPath, verbose)
-				createTerraformFile(interfaceDir, validOutputs, state, schema, verbose)
-				createProviderFile(interfaceDir, validOutputs, schema)
-				createOutputsFile(interfaceDir, validOutputs, verbose)
-			}
+			return fmt.Errorf("executable not found: %s", executable)
+		}
+		if opts.Verbose {
+			log.Printf("Using %s for project %s", executablePath, project.Path)
 		}
-		if err := os.Chdir(currentDir); err != nil {
-			log.Fatalf("Failed to return to the original directory: %v", err)
+	}
+	return nil
+}
+
+func main() {
+	args := os.Args[1:]
+	name := "generate"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name = args[0]
+		args = args[1:]
+	}
+	if name == "help" {
+		printUsage(os.Stdout)
+		return
+	}
+	for _, cmd := range commands() {
+		if cmd.Name == name {
+			os.Exit(cmd.Run(args))
 		}
 	}
+	fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", name)
+	printUsage(os.Stderr)
+	os.Exit(2)
 }
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
 	"testing"
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
-	"gopkg.in/yaml.v2"
 )
 
 func TestReadConfig(t *testing.T) {
@@ -53,10 +56,14 @@ projects
 }
 
 func TestFindAnnotatedOutputs(t *testing.T) {
-	fs := afero.NewMemMapFs()
+	scan := func(content string) []AnnotatedOutput {
+		fs := afero.NewMemMapFs()
+		afero.WriteFile(fs, "/main.tf", []byte(content), 0644)
+		return findAnnotatedOutputs(fs, "/", false)
+	}
 
 	// File with four outputs, none annotated
-	content1 := `
+	outputs := scan(`
 output "output1" {
   value = "value1"
 }
@@ -69,13 +76,11 @@ output "output3" {
 output "output4" {
   value = "value4"
 }
-`
-	afero.WriteFile(fs, "/test1.tf", []byte(content1), 0644)
-	outputs := findAnnotatedOutputs(fs, "/", false)
+`)
 	assert.Equal(t, 0, len(outputs))
 
 	// File with four outputs, one annotated
-	content2 := `
+	outputs = scan(`
 # @public
 output "output1" {
   value = "value1"
@@ -89,15 +94,13 @@ output "output3" {
 output "output4" {
   value = "value4"
 }
-`
-	afero.WriteFile(fs, "/test2.tf", []byte(content2), 0644)
-	outputs = findAnnotatedOutputs(fs, "/", false)
+`)
 	assert.Equal(t, 1, len(outputs))
 	assert.Equal(t, "output1", outputs[0].Output)
-	assert.Equal(t, "value1", outputs[0].Reference)
+	assert.Equal(t, `"value1"`, outputs[0].Reference)
 
 	// File with four outputs, three annotated
-	content3 := `
+	outputs = scan(`
 # @public
 output "output1" {
   value = "value1"
@@ -113,42 +116,36 @@ output "output3" {
 output "output4" {
   value = "value4"
 }
-`
-	afero.WriteFile(fs, "/test3.tf", []byte(content3), 0644)
-	outputs = findAnnotatedOutputs(fs, "/", false)
+`)
 	assert.Equal(t, 3, len(outputs))
 	assert.Equal(t, "output1", outputs[0].Output)
-	assert.Equal(t, "value1", outputs[0].Reference)
+	assert.Equal(t, `"value1"`, outputs[0].Reference)
 	assert.Equal(t, "output2", outputs[1].Output)
-	assert.Equal(t, "value2", outputs[1].Reference)
+	assert.Equal(t, `"value2"`, outputs[1].Reference)
 	assert.Equal(t, "output3", outputs[2].Output)
-	assert.Equal(t, "value3", outputs[2].Reference)
+	assert.Equal(t, `"value3"`, outputs[2].Reference)
 
 	// File with one output, one annotated
-	content4 := `
+	outputs = scan(`
 # @public
 output "output1" {
   value = "value1"
 }
-`
-	afero.WriteFile(fs, "/test4.tf", []byte(content4), 0644)
-	outputs = findAnnotatedOutputs(fs, "/", false)
+`)
 	assert.Equal(t, 1, len(outputs))
 	assert.Equal(t, "output1", outputs[0].Output)
-	assert.Equal(t, "value1", outputs[0].Reference)
+	assert.Equal(t, `"value1"`, outputs[0].Reference)
 
 	// File with one output, none annotated
-	content5 := `
+	outputs = scan(`
 output "output1" {
   value = "value1"
 }
-`
-	afero.WriteFile(fs, "/test5.tf", []byte(content5), 0644)
-	outputs = findAnnotatedOutputs(fs, "/", false)
+`)
 	assert.Equal(t, 0, len(outputs))
 
 	// File with mixed content
-	content6 := `
+	outputs = scan(`
 resource "random_pet" "my_random_pet" {
   length = 2
   separator = "-"
@@ -165,9 +162,7 @@ resource "random_string" "my_random_string" {
 output "output3" {
   value = random_string.my_random_string.result
 }
-`
-	afero.WriteFile(fs, "/test6.tf", []byte(content6), 0644)
-	outputs = findAnnotatedOutputs(fs, "/", false)
+`)
 	assert.Equal(t, 2, len(outputs))
 	assert.Equal(t, "output1", outputs[0].Output)
 	assert.Equal(t, "random_pet.my_random_pet.id", outputs[0].Reference)
@@ -175,13 +170,11 @@ output "output3" {
 	assert.Equal(t, "random_string.my_random_string.result", outputs[1].Reference)
 
 	// Empty file
-	content7 := ``
-	afero.WriteFile(fs, "/test7.tf", []byte(content7), 0644)
-	outputs = findAnnotatedOutputs(fs, "/", false)
+	outputs = scan(``)
 	assert.Equal(t, 0, len(outputs))
 
 	// File with multiple @public annotations but incomplete output blocks
-	content8 := `
+	outputs = scan(`
 # @public
 output "output1" {
   value = "value1"
@@ -190,66 +183,79 @@ output "output1" {
 output "output2" {
   value = "value2"
 }
-`
-	afero.WriteFile(fs, "/test8.tf", []byte(content8), 0644)
+`)
+	assert.Equal(t, 2, len(outputs))
+	assert.Equal(t, "output1", outputs[0].Output)
+	assert.Equal(t, `"value1"`, outputs[0].Reference)
+	assert.Equal(t, "output2", outputs[1].Output)
+	assert.Equal(t, `"value2"`, outputs[1].Reference)
+
+	// Annotations in several files are all found
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/a.tf", []byte("# @public\noutput \"output1\" {\n  value = aws_vpc.main.id\n}\n"), 0644)
+	afero.WriteFile(fs, "/b.tf", []byte("# @public\noutput \"output2\" {\n  value = aws_subnet.main.id\n}\n"), 0644)
 	outputs = findAnnotatedOutputs(fs, "/", false)
 	assert.Equal(t, 2, len(outputs))
 	assert.Equal(t, "output1", outputs[0].Output)
-	assert.Equal(t, "value1", outputs[0].Reference)
 	assert.Equal(t, "output2", outputs[1].Output)
-	assert.Equal(t, "value2", outputs[1].Reference)
 }
 
-func TestFilterValidOutputs(t *testing.T) {
-	schema := ProviderSchema{
-		ProviderSchemas: map[string]ProviderSchemaDetails{
-			"provider1": {
-				ResourceSchemas: map[string]ResourceSchema{
-					"resource1": {},
-				},
-				DataSourceSchemas: map[string]ResourceSchema{
-					"data1": {},
-				},
+// testSchema has a data source for each of resource1 and resource2, which their outputs can be read with.
+var testSchema = ProviderSchema{
+	ProviderSchemas: map[string]ProviderSchemaDetails{
+		"registry.terraform.io/hashicorp/provider1": {
+			ResourceSchemas: map[string]ResourceSchema{
+				"resource1": {},
 			},
-			"provider2": {
-				ResourceSchemas: map[string]ResourceSchema{
-					"resource2": {},
-				},
-				DataSourceSchemas: map[string]ResourceSchema{
-					"data2": {},
-				},
+			DataSourceSchemas: map[string]ResourceSchema{
+				"resource1": {Block: ResourceBlock{Attributes: map[string]Attribute{"attribute1": {Computed: true}}}},
 			},
 		},
-	}
+		"registry.terraform.io/hashicorp/provider2": {
+			ResourceSchemas: map[string]ResourceSchema{
+				"resource2": {},
+				"resource3": {},
+			},
+			DataSourceSchemas: map[string]ResourceSchema{
+				"resource2": {Block: ResourceBlock{Attributes: map[string]Attribute{"attribute1": {Computed: true}}}},
+			},
+		},
+	},
+}
+
+func TestFilterValidOutputs(t *testing.T) {
 	state := TerraformState{}
 
 	t.Run("No valid outputs", func(t *testing.T) {
 		outputs := []AnnotatedOutput{
-			{Output: "output1", Reference: "invalid_resource1.instance1"},
+			{Output: "output1", Reference: "invalid_resource1.instance1.attribute1"},
+			{Output: "output2", Reference: "resource3.instance3.attribute1"},
 		}
-		validOutputs := filterValidOutputs(outputs, schema, state, false)
+		validOutputs := filterValidOutputs(outputs, testSchema, state, false)
 		assert.Equal(t, 0, len(validOutputs))
 	})
 
 	t.Run("Some valid outputs", func(t *testing.T) {
 		outputs := []AnnotatedOutput{
-			{Output: "output1", Reference: "resource1.instance1"},
-			{Output: "output2", Reference: "invalid_resource2.instance2"},
+			{Output: "output1", Reference: "resource1.instance1.attribute1"},
+			{Output: "output2", Reference: "invalid_resource2.instance2.attribute1"},
+		}
+		validOutputs := filterValidOutputs(outputs, testSchema, state, false)
+		if assert.Equal(t, 1, len(validOutputs)) {
+			assert.Equal(t, "output1", validOutputs[0].Output)
 		}
-		validOutputs := filterValidOutputs(outputs, schema, state, false)
-		assert.Equal(t, 1, len(validOutputs))
-		assert.Equal(t, "output1", validOutputs[0].Output)
 	})
 
 	t.Run("All valid outputs", func(t *testing.T) {
 		outputs := []AnnotatedOutput{
-			{Output: "output1", Reference: "resource1.instance1"},
-			{Output: "output2", Reference: "resource2.instance2"},
+			{Output: "output1", Reference: "resource1.instance1.attribute1"},
+			{Output: "output2", Reference: "resource2.instance2.attribute1"},
+		}
+		validOutputs := filterValidOutputs(outputs, testSchema, state, false)
+		if assert.Equal(t, 2, len(validOutputs)) {
+			assert.Equal(t, "output1", validOutputs[0].Output)
+			assert.Equal(t, "output2", validOutputs[1].Output)
 		}
-		validOutputs := filterValidOutputs(outputs, schema, state, false)
-		assert.Equal(t, 2, len(validOutputs))
-		assert.Equal(t, "output1", validOutputs[0].Output)
-		assert.Equal(t, "output2", validOutputs[1].Output)
 	})
 }
 
@@ -258,7 +264,18 @@ func TestIntegration(t *testing.T) {
 
 	// Setup a mock Terraform project
 	projectPath := "/project"
-	afero.WriteFile(fs, projectPath+"/main.tf", []byte(`
+	afero.WriteFile(fs, projectPath+"/main.tf", []byte(testProject), 0644)
+
+	// Run the integration test
+	outputs := findAnnotatedOutputs(fs, projectPath, false)
+	validOutputs := filterValidOutputs(outputs, testSchema, TerraformState{}, false)
+	if assert.Equal(t, 2, len(validOutputs)) {
+		assert.Equal(t, "output1", validOutputs[0].Output)
+		assert.Equal(t, "output2", validOutputs[1].Output)
+	}
+}
+
+const testProject = `
 resource "resource1" "instance1" {
   attribute1 = "value1"
 }
@@ -277,127 +294,94 @@ output "output2" {
 output "output3" {
   value = "value3"
 }
-`), 0644)
-
-	// Mock Terraform state
-	state := TerraformState{
-		Values: struct {
-			RootModule struct {
-				Resources []struct {
-					Address string                 `json:"address"`
-					Values  map[string]interface{} `json:"values"`
-				} `json:"resources"`
-				Outputs map[string]struct {
-					Value interface{} `json:"value"`
-				} `json:"outputs"`
-			} `json:"root_module"`
-		}{
-			RootModule: struct {
-				Resources []struct {
-					Address string                 `json:"address"`
-					Values  map[string]interface{} `json:"values"`
-				} `json:"resources"`
-				Outputs map[string]struct {
-					Value interface{} `json:"value"`
-				} `json:"outputs"`
-			}{
-				Resources: []struct {
-					Address string                 `json:"address"`
-					Values  map[string]interface{} `json:"values"`
-				}{
-					{
-						Address: "resource1.instance1",
-						Values: map[string]interface{}{
-							"attribute1": "value1",
-						},
-					},
-					{
-						Address: "resource2.instance2",
-						Values: map[string]interface{}{
-							"attribute1": "value2",
-						},
-					},
-				},
-			},
-		},
-	}
-
-	// Mock provider schema
-	schema := ProviderSchema{
-		ProviderSchemas: map[string]ProviderSchemaDetails{
-			"provider1": {
-				ResourceSchemas: map[string]ResourceSchema{
-					"resource1": {},
-				},
-				DataSourceSchemas: map[string]ResourceSchema{
-					"data1": {},
-				},
-			},
-			"provider2": {
-				ResourceSchemas: map[string]ResourceSchema{
-					"resource2": {},
-				},
-				DataSourceSchemas: map[string]ResourceSchema{
-					"data2": {},
-				},
-			},
-		},
-	}
+`
 
-	// Run the integration test
-	outputs := findAnnotatedOutputs(fs, projectPath, false)
-	validOutputs := filterValidOutputs(outputs, schema, state, false)
-	assert.Equal(t, 2, len(validOutputs))
-	assert.Equal(t, "output1", validOutputs[0].Output)
-	assert.Equal(t, "output2", validOutputs[1].Output)
+// fakeTerraform writes a script to dir that answers show -json and providers schema -json like terraform would for
+// testProject, and returns its path.
+func fakeTerraform(t *testing.T, dir string) string {
+	state := `{"values": {"root_module": {"resources": [
+  {"address": "resource1.instance1", "values": {"attribute1": "value1"}},
+  {"address": "resource2.instance2", "values": {"attribute1": "value2"}}
+]}}}`
+	schema, err := json.Marshal(testSchema)
+	assert.Nil(t, err)
+	afero.WriteFile(afero.NewOsFs(), filepath.Join(dir, "show.json"), []byte(state), 0644)
+	afero.WriteFile(afero.NewOsFs(), filepath.Join(dir, "schema.json"), schema, 0644)
+	path := filepath.Join(dir, "terraform")
+	script := "#!/bin/sh\ncase \"$1\" in\nshow) cat " + filepath.Join(dir, "show.json") + " ;;\nproviders) cat " + filepath.Join(dir, "schema.json") + " ;;\n*) exit 1 ;;\nesac\n"
+	afero.WriteFile(afero.NewOsFs(), path, []byte(script), 0755)
+	return path
 }
 
-func TestProcessProject(t *testing.T) {
-	fs := afero.NewMemMapFs()
-	currentDir := "/"
-	shell := "bash"
-	command := "terraform"
+func TestPlanAndApplyProject(t *testing.T) {
+	fs := afero.NewOsFs()
+	currentDir := t.TempDir()
+	settings := ProjectSettings{Shell: "bash", Command: fakeTerraform(t, t.TempDir())}
 	verbose := false
+	var out bytes.Buffer
 
 	// Valid project
 	project := ProjectConfig{Path: "valid_project"}
-	fs.MkdirAll("/valid_project", 0755)
-	afero.WriteFile(fs, "/valid_project/main.tf", []byte(`
-resource "resource1" "instance1" {
-  attribute1 = "value1"
-}
-resource "resource2" "instance2" {
-  attribute1 = "value2"
-}
-# @public
-output "output1" {
-  value = resource1.instance1.attribute1
-}
-# @public
-output "output2" {
-  value = resource2.instance2.attribute1
-}
-`), 0644)
+	fs.MkdirAll(filepath.Join(currentDir, "valid_project"), 0755)
+	afero.WriteFile(fs, filepath.Join(currentDir, "valid_project", "main.tf"), []byte(testProject), 0644)
 
-	err := processProject(fs, project, currentDir, shell, command, verbose)
+	plan, err := planProject(context.Background(), ExecRunner{}, fs, project, currentDir, settings, verbose)
 	assert.Nil(t, err)
-	assert.FileExists(t, "/valid_project/interface/generated_data.tf")
-	assert.FileExists(t, "/valid_project/interface/generated_providers.tf")
-	assert.FileExists(t, "/valid_project/interface/generated_outputs.tf")
+	assert.Nil(t, applyPlan(&out, fs, plan, verbose))
+	assert.FileExists(t, filepath.Join(currentDir, "valid_project/interface/generated_data.tf"))
+	assert.FileExists(t, filepath.Join(currentDir, "valid_project/interface/generated_providers.tf"))
+	assert.FileExists(t, filepath.Join(currentDir, "valid_project/interface/generated_outputs.tf"))
 
 	// Non-existent project path
 	project = ProjectConfig{Path: "non_existent_project"}
-	err = processProject(fs, project, currentDir, shell, command, verbose)
+	_, err = planProject(context.Background(), ExecRunner{}, fs, project, currentDir, settings, verbose)
 	assert.NotNil(t, err)
 
 	// No annotated outputs
 	project = ProjectConfig{Path: "no_annotated_outputs_project"}
-	fs.MkdirAll("/no_annotated_outputs_project", 0755)
-	afero.WriteFile(fs, "/no_annotated_outputs_project/main.tf", []byte(`
+	fs.MkdirAll(filepath.Join(currentDir, "no_annotated_outputs_project"), 0755)
+	afero.WriteFile(fs, filepath.Join(currentDir, "no_annotated_outputs_project", "main.tf"), []byte(`
 output "output1" {
   value = "value1"
 }
 `), 0644)
-	err = processProject(fs, project, currentDir, shell, command, verbose)
+	plan, err = planProject(context.Background(), ExecRunner{}, fs, project, currentDir, settings, verbose)
 	assert.Nil(t, err)
+	out.Reset()
+	assert.Nil(t, applyPlan(&out, fs, plan, verbose))
+	assert.Contains(t, out.String(), "No Annotated Outputs")
+	assert.NoFileExists(t, filepath.Join(currentDir, "no_annotated_outputs_project/interface/generated_outputs.tf"))
+}
+
+func TestSkipReason(t *testing.T) {
+	schema := ProviderSchema{ProviderSchemas: map[string]ProviderSchemaDetails{"registry.terraform.io/hashicorp/aws": {DataSourceSchemas: map[string]ResourceSchema{
+		"aws_instance": {Block: ResourceBlock{
+			Attributes: map[string]Attribute{"instance_id": {Optional: true}, "arn": {Computed: true}},
+			BlockTypes: map[string]NestedBlock{"root_block_device": {NestingMode: "set"}},
+		}},
+		"aws_db_instance": {Block: ResourceBlock{Attributes: map[string]Attribute{"db_instance_identifier": {Required: true}, "db_instance_arn": {Computed: true}}}},
+	}}}}
+
+	assert.Equal(t, "", skipReason(AnnotatedOutput{Reference: "aws_instance.web.arn"}, schema))
+	assert.Equal(t, "", skipReason(AnnotatedOutput{Reference: "aws_instance.web.root_block_device"}, schema))
+	assert.Equal(t, "data source aws_instance has no attribute user_data", skipReason(AnnotatedOutput{Reference: "aws_instance.web.user_data"}, schema))
+	assert.Equal(t, "", skipReason(AnnotatedOutput{Reference: "aws_db_instance.main.arn"}, schema))
+	assert.Equal(t, "data.aws_db_instance.main.db_instance_arn", outputValue(AnnotatedOutput{Reference: "aws_db_instance.main.arn"}))
+	assert.Equal(t, "data source aws_db_instance has no attribute db_instance_identifier (attribute identifier of the resource)",
+		skipReason(AnnotatedOutput{Reference: "aws_db_instance.main.identifier"}, ProviderSchema{ProviderSchemas: map[string]ProviderSchemaDetails{"aws": {DataSourceSchemas: map[string]ResourceSchema{"aws_db_instance": {}}}}}))
+}
+
+func TestPlanDataSourcesRenamedLookup(t *testing.T) {
+	schema := ProviderSchema{ProviderSchemas: map[string]ProviderSchemaDetails{"registry.terraform.io/hashicorp/aws": {DataSourceSchemas: map[string]ResourceSchema{
+		"aws_db_instance": {Block: ResourceBlock{Attributes: map[string]Attribute{"db_instance_identifier": {Required: true}}}},
+	}}}}
+	var state TerraformState
+	state.Values.RootModule.Resources = append(state.Values.RootModule.Resources, struct {
+		Address string                 `json:"address"`
+		Values  map[string]interface{} `json:"values"`
+	}{Address: "aws_db_instance.main", Values: map[string]interface{}{"identifier": "orders"}})
+
+	dataSources, warnings := planDataSources([]AnnotatedOutput{{Reference: "aws_db_instance.main.arn"}}, state, schema, false)
+	assert.Empty(t, warnings)
+	assert.Equal(t, []LookupValue{{Attribute: "db_instance_identifier", Value: "orders", Found: true}}, dataSources[0].Lookups)
 }
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+const manifestFileName = "generated_manifest.json"
+
+// toolVersion is the version of tf-interfaces recorded in manifests. Releases set it with
+// -ldflags "-X main.toolVersion=1.2.3".
+var toolVersion = "dev"
+
+// Manifest describes where an interface module came from and what it exports, for tools that consume interfaces.
+type Manifest struct {
+	FormatVersion  int                `json:"format_version"`
+	Project        string             `json:"project"`
+	Owner          string             `json:"owner,omitempty"`
+	Audience       string             `json:"audience"`
+	ToolVersion    string             `json:"tool_version"`
+	ProducerCommit string             `json:"producer_commit,omitempty"`
+	Outputs        []ManifestOutput   `json:"outputs"`
+	Providers      []ManifestProvider `json:"providers"`
+	// ContentHash is the SHA-256 of the module's generated Terraform files, so consumers can tell whether two
+	// manifests describe the same module.
+	ContentHash string `json:"content_hash"`
+}
+
+type ManifestOutput struct {
+	Name             string      `json:"name"`
+	Output           string      `json:"output"`
+	Description      string      `json:"description,omitempty"`
+	File             string      `json:"file"`
+	Line             int         `json:"line"`
+	Reference        string      `json:"reference"`
+	Value            string      `json:"value"`
+	Strategy         string      `json:"strategy"`
+	DataSource       string      `json:"data_source"`
+	LookupAttributes []string    `json:"lookup_attributes"`
+	Type             interface{} `json:"type,omitempty"`
+	Sensitive        bool        `json:"sensitive"`
+	Group            string      `json:"group,omitempty"`
+	Since            string      `json:"since,omitempty"`
+	Stability        string      `json:"stability,omitempty"`
+	Deprecation      string      `json:"deprecation,omitempty"`
+}
+
+type ManifestProvider struct {
+	Source      string `json:"source"`
+	Version     string `json:"version,omitempty"`
+	Constraints string `json:"constraints,omitempty"`
+}
+
+// ProducerInfo is what a manifest records about the producer project beyond its outputs.
+type ProducerInfo struct {
+	Commit string
+	// Locks are the provider versions and constraints in the project's .terraform.lock.hcl.
+	Locks []ManifestProvider
+}
+
+var (
+	lockProviderPattern    = regexp.MustCompile(`^provider\s+"([^"]+)"`)
+	lockVersionPattern     = regexp.MustCompile(`^\s*version\s*=\s*"([^"]*)"`)
+	lockConstraintsPattern = regexp.MustCompile(`^\s*constraints\s*=\s*"([^"]*)"`)
+)
+
+// producerInfo reads the commit the project is checked out at, if it is in a git repository, and its provider locks.
+func producerInfo(ctx context.Context, fs afero.Fs, projectPath string) (ProducerInfo, error) {
+	info := ProducerInfo{}
+	if output, err := exec.CommandContext(ctx, "git", "-C", projectPath, "rev-parse", "HEAD").Output(); err == nil {
+		info.Commit = strings.TrimSpace(string(output))
+	}
+	content, _, err := fsReader(fs)(filepath.Join(projectPath, ".terraform.lock.hcl"))
+	if err != nil {
+		return info, err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if matches := lockProviderPattern.FindStringSubmatch(line); matches != nil {
+			info.Locks = append(info.Locks, ManifestProvider{Source: matches[1]})
+		} else if len(info.Locks) == 0 {
+			continue
+		} else if matches := lockVersionPattern.FindStringSubmatch(line); matches != nil {
+			info.Locks[len(info.Locks)-1].Version = matches[1]
+		} else if matches := lockConstraintsPattern.FindStringSubmatch(line); matches != nil {
+			info.Locks[len(info.Locks)-1].Constraints = matches[1]
+		}
+	}
+	return info, nil
+}
+
+// providerAddress is the namespace and type of a provider source, which the lock file and the provider schema agree
+// on even when one says registry.terraform.io and the other registry.opentofu.org.
+func providerAddress(source string) string {
+	parts := strings.Split(source, "/")
+	if len(parts) < 2 {
+		return source
+	}
+	return strings.Join(parts[len(parts)-2:], "/")
+}
+
+// buildManifest builds the manifest of an audience's interface module from the files rendered for it.
+func buildManifest(module InterfacePlan, plan ProjectPlan, files []GeneratedFile, state TerraformState, schema ProviderSchema, producer ProducerInfo) Manifest {
+	manifest := Manifest{
+		FormatVersion:  1,
+		Project:        plan.Project.Path,
+		Owner:          plan.Project.Owner,
+		Audience:       module.Audience,
+		ToolVersion:    toolVersion,
+		ProducerCommit: producer.Commit,
+		Outputs:        []ManifestOutput{},
+		Providers:      []ManifestProvider{},
+	}
+	sources := make(map[string]bool)
+	for _, output := range module.Outputs {
+		manifestOutput := ManifestOutput{
+			Name:             output.ExportedName(),
+			Output:           output.Output,
+			Description:      output.Annotation.Description,
+			File:             output.File,
+			Line:             output.Line,
+			Reference:        output.Reference,
+			Value:            outputValue(output),
+			Strategy:         output.Annotation.Strategy,
+			DataSource:       "data.terraform_remote_state.this",
+			LookupAttributes: []string{},
+			Group:            output.Annotation.Group,
+			Since:            output.Annotation.Since,
+			Stability:        output.Annotation.Stability,
+			Deprecation:      output.Annotation.Deprecation,
+		}
+		if stateOutput, exists := state.Outputs[output.Output]; exists {
+			manifestOutput.Type = stateOutput.Type
+			manifestOutput.Sensitive = stateOutput.Sensitive
+		}
+		if output.Annotation.Strategy != strategyRemoteState {
+			parts := strings.Split(output.Reference, ".")
+			manifestOutput.DataSource = "data." + parts[0] + "." + parts[1]
+			for _, dataSource := range plan.DataSources {
+				if dataSource.Type == parts[0] && dataSource.Name == parts[1] {
+					for _, lookup := range dataSource.Lookups {
+						manifestOutput.LookupAttributes = append(manifestOutput.LookupAttributes, lookup.Attribute)
+					}
+					sources[dataSource.Provider] = true
+				}
+			}
+			if attribute, exists := dataSourceAttribute(parts[0], dataSourceAttributeName(parts[0], parts[2]), schema); exists {
+				manifestOutput.Type = attribute.Type
+				manifestOutput.Sensitive = manifestOutput.Sensitive || attribute.Sensitive
+			}
+		}
+		manifest.Outputs = append(manifest.Outputs, manifestOutput)
+	}
+	for _, source := range sortedSet(sources) {
+		provider := ManifestProvider{Source: source}
+		for _, lock := range producer.Locks {
+			if providerAddress(lock.Source) == providerAddress(source) {
+				provider.Version, provider.Constraints = lock.Version, lock.Constraints
+			}
+		}
+		manifest.Providers = append(manifest.Providers, provider)
+	}
+	manifest.ContentHash = contentHash(module.Dir, files)
+	return manifest
+}
+
+func renderManifest(dir string, manifest Manifest) GeneratedFile {
+	content, _ := json.MarshalIndent(manifest, "", "  ")
+	return GeneratedFile{Path: filepath.Join(dir, manifestFileName), Content: string(content) + "\n"}
+}
+
+func dataSourceAttribute(dataSourceType string, attribute string, schema ProviderSchema) (Attribute, bool) {
+	for _, providerSchema := range schema.ProviderSchemas {
+		if dataSourceSchema, exists := providerSchema.DataSourceSchemas[dataSourceType]; exists {
+			value, exists := dataSourceSchema.Block.Attributes[attribute]
+			return value, exists
+		}
+	}
+	return Attribute{}, false
+}
+
+// contentHash hashes the files below dir by their path relative to dir and their content.
+func contentHash(dir string, files []GeneratedFile) string {
+	var paths []string
+	contents := make(map[string]string)
+	for _, file := range files {
+		if relativePath, err := filepath.Rel(dir, file.Path); err == nil && !strings.HasPrefix(relativePath, "..") {
+			paths = append(paths, filepath.ToSlash(relativePath))
+			contents[filepath.ToSlash(relativePath)] = file.Content
+		}
+	}
+	sort.Strings(paths)
+	hash := sha256.New()
+	for _, path := range paths {
+		hash.Write([]byte(path + "\x00" + contents[path] + "\x00"))
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// sameManifest reports whether two manifests only differ in the tool version and the producer commit, which change
+// without the interface changing.
+func sameManifest(existing string, generated string) bool {
+	var a, b Manifest
+	if json.Unmarshal([]byte(existing), &a) != nil || json.Unmarshal([]byte(generated), &b) != nil {
+		return false
+	}
+	a.ToolVersion, a.ProducerCommit = b.ToolVersion, b.ProducerCommit
+	first, _ := json.Marshal(a)
+	second, _ := json.Marshal(b)
+	return string(first) == string(second)
+}
+
+func sortedSet(values map[string]bool) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProducerInfo(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/project/.terraform.lock.hcl", []byte(`provider "registry.opentofu.org/hashicorp/aws" {
+  version     = "5.40.0"
+  constraints = "~> 5.0"
+  hashes = [
+    "h1:abc=",
+  ]
+}
+
+provider "registry.terraform.io/hashicorp/local" {
+  version = "2.5.1"
+}
+`), 0644)
+
+	info, err := producerInfo(context.Background(), fs, "/project")
+	assert.Nil(t, err)
+	assert.Equal(t, []ManifestProvider{
+		{Source: "registry.opentofu.org/hashicorp/aws", Version: "5.40.0", Constraints: "~> 5.0"},
+		{Source: "registry.terraform.io/hashicorp/local", Version: "2.5.1"},
+	}, info.Locks)
+	assert.Equal(t, "hashicorp/aws", providerAddress("registry.terraform.io/hashicorp/aws"))
+
+	info, err = producerInfo(context.Background(), fs, "/other")
+	assert.Nil(t, err)
+	assert.Empty(t, info.Locks)
+}
+
+func TestSameManifest(t *testing.T) {
+	manifest := Manifest{FormatVersion: 1, Project: "stacks/network", Audience: audiencePublic, ToolVersion: "1.0.0", ProducerCommit: "abc", ContentHash: "sha256:1"}
+	existing, _ := json.Marshal(manifest)
+	manifest.ToolVersion, manifest.ProducerCommit = "1.1.0", "def"
+	generated, _ := json.Marshal(manifest)
+	assert.True(t, sameManifest(string(existing), string(generated)))
+
+	manifest.ContentHash = "sha256:2"
+	generated, _ = json.Marshal(manifest)
+	assert.False(t, sameManifest(string(existing), string(generated)))
+	assert.False(t, sameManifest("not json", string(generated)))
+
+	files := []GeneratedFile{{Path: "/i/b.tf", Content: "b"}, {Path: "/i/a.tf", Content: "a"}, {Path: "/other/c.tf", Content: "c"}}
+	assert.Equal(t, contentHash("/i", files), contentHash("/i", files[:2]))
+	assert.NotEqual(t, contentHash("/i", files), contentHash("/i", files[1:]))
+}
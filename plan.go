@@ -0,0 +1,470 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+type LookupValue struct {
+	Attribute string
+	Value     interface{}
+	Found     bool
+	Sensitive bool
+}
+
+type DataSourcePlan struct {
+	Type     string
+	Name     string
+	Provider string
+	Lookups  []LookupValue
+}
+
+type SkippedOutput struct {
+	Output AnnotatedOutput
+	Reason string
+}
+
+// InterfacePlan is the interface module generated for one audience.
+type InterfacePlan struct {
+	Audience string
+	Dir      string
+	Outputs  []AnnotatedOutput
+	// Groups are the groups of the outputs, each generated as a submodule in modules/<group> below Dir.
+	Groups []string
+}
+
+type GeneratedFile struct {
+	Path    string
+	Content string
+}
+
+// ProjectPlan is everything a run would do for one project, computed without touching the interface directory.
+type ProjectPlan struct {
+	Project      ProjectConfig
+	Settings     ProjectSettings
+	FullPath     string
+	InterfaceDir string
+	Outputs      []AnnotatedOutput
+	DataSources  []DataSourcePlan
+	// RemoteState is the backend that outputs with the remote_state strategy are read through, if there are any.
+	RemoteState *Backend
+	Skipped     []SkippedOutput
+	Warnings    []string
+	Interfaces  []InterfacePlan
+	Files       []GeneratedFile
+	// LintProblems are the ways the plan breaks the interface modules on disk, such as removing a stable output.
+	LintProblems []string
+	// StateOutputs are the producer's outputs in state, which the interface modules should read the same values as.
+	StateOutputs map[string]StateOutput
+}
+
+func planProject(ctx context.Context, runner Runner, fs afero.Fs, project ProjectConfig, currentDir string, settings ProjectSettings, verbose bool) (ProjectPlan, error) {
+	fullPath := filepath.Join(currentDir, project.Path)
+	plan := ProjectPlan{
+		Project:      project,
+		Settings:     settings,
+		FullPath:     fullPath,
+		InterfaceDir: interfaceDirectory(currentDir, project, audiencePublic),
+	}
+	if info, err := fs.Stat(fullPath); err != nil || !info.IsDir() {
+		return plan, fmt.Errorf("Terraform project %s is not a directory", fullPath)
+	}
+	if isTerragruntUnit(fs, fullPath) {
+		runner = TerragruntRunner{Runner: runner}
+		settings = terragruntSettings(settings)
+		plan.Settings = settings
+	}
+	if verbose {
+		logSettings(project, settings)
+	}
+	state, err := fetchTerraformState(ctx, runner, settings, fullPath)
+	if err != nil {
+		return plan, fmt.Errorf("failed to fetch Terraform state: %v", err)
+	}
+	plan.StateOutputs = state.Outputs
+	schema, err := fetchProviderSchema(ctx, runner, settings, fullPath)
+	if err != nil {
+		return plan, fmt.Errorf("failed to fetch provider schema: %v", err)
+	}
+	plan.Outputs, err = projectOutputs(fs, fullPath, verbose)
+	if err != nil {
+		return plan, fmt.Errorf("failed to find annotated outputs: %v", err)
+	}
+	var remoteStateOutputs, dataSourceOutputs []AnnotatedOutput
+	for _, output := range plan.Outputs {
+		if reason := skipReason(output, schema); reason != "" {
+			plan.Skipped = append(plan.Skipped, SkippedOutput{Output: output, Reason: reason})
+		} else if output.Annotation.Strategy == strategyRemoteState {
+			remoteStateOutputs = append(remoteStateOutputs, output)
+		} else {
+			dataSourceOutputs = append(dataSourceOutputs, output)
+		}
+	}
+	if len(remoteStateOutputs) > 0 {
+		moduleDir, err := projectModuleDir(fs, fullPath, verbose)
+		if err != nil {
+			return plan, err
+		}
+		plan.RemoteState, err = findBackend(fs, moduleDir)
+		if err != nil {
+			return plan, fmt.Errorf("failed to read the backend block: %v", err)
+		}
+		if plan.RemoteState != nil {
+			plan.RemoteState.Workspace = settings.Workspace
+		} else {
+			for _, output := range remoteStateOutputs {
+				plan.Skipped = append(plan.Skipped, SkippedOutput{Output: output, Reason: "strategy remote_state needs a backend block in the project"})
+			}
+			remoteStateOutputs = nil
+		}
+	}
+	skipped := make(map[string]bool)
+	for _, skippedOutput := range plan.Skipped {
+		skipped[skippedOutput.Output.Output] = true
+	}
+	var validOutputs []AnnotatedOutput
+	for _, output := range plan.Outputs {
+		if !skipped[output.Output] {
+			validOutputs = append(validOutputs, output)
+		}
+	}
+	if len(validOutputs) == 0 {
+		plan.LintProblems, err = lintStability(fs, plan)
+		return plan, err
+	}
+	plan.DataSources, plan.Warnings = planDataSources(dataSourceOutputs, state, schema, verbose)
+	var unresolved []SkippedOutput
+	plan.DataSources, unresolved, err = resolveLookups(project.Unresolved, plan.DataSources, validOutputs)
+	if err != nil {
+		return plan, err
+	}
+	plan.Skipped = append(plan.Skipped, unresolved...)
+	for _, skippedOutput := range unresolved {
+		skipped[skippedOutput.Output.Output] = true
+	}
+	validOutputs = nil
+	for _, output := range plan.Outputs {
+		if !skipped[output.Output] {
+			validOutputs = append(validOutputs, output)
+		}
+	}
+	producer, err := producerInfo(ctx, fs, fullPath)
+	if err != nil {
+		return plan, fmt.Errorf("failed to read the provider lock file: %v", err)
+	}
+	for _, audience := range sortedAudiences(validOutputs) {
+		module := InterfacePlan{Audience: audience, Dir: interfaceDirectory(currentDir, project, audience)}
+		for _, output := range validOutputs {
+			if hasAudience(output, audience) {
+				module.Outputs = append(module.Outputs, output)
+				if output.Annotation.Group != "" {
+					module.Groups = appendUnique(module.Groups, output.Annotation.Group)
+				}
+			}
+		}
+		sort.Strings(module.Groups)
+		plan.Interfaces = append(plan.Interfaces, module)
+		files := renderInterface(module, plan.DataSources, plan.RemoteState, schema)
+		plan.Files = append(plan.Files, files...)
+		manifest := buildManifest(module, plan, files, state, schema, producer)
+		readme, err := renderReadme(fs, module, manifest, currentDir)
+		if err != nil {
+			return plan, err
+		}
+		plan.Files = append(plan.Files, renderManifest(module.Dir, manifest), readme)
+	}
+	plan.LintProblems, err = lintStability(fs, plan)
+	return plan, err
+}
+
+// renderInterface renders the files of an audience's interface module. Outputs without a group are read in the
+// module itself. Each group is a submodule with only the data sources and providers its outputs need, and the module
+// composes the submodules and exports their outputs as well.
+func renderInterface(module InterfacePlan, dataSources []DataSourcePlan, remoteState *Backend, schema ProviderSchema) []GeneratedFile {
+	var ungrouped []AnnotatedOutput
+	grouped := make(map[string][]AnnotatedOutput)
+	for _, output := range module.Outputs {
+		if output.Annotation.Group == "" {
+			ungrouped = append(ungrouped, output)
+		} else {
+			grouped[output.Annotation.Group] = append(grouped[output.Annotation.Group], output)
+		}
+	}
+	files := renderModuleSources(module.Dir, ungrouped, dataSources, remoteState, schema)
+	variables := lookupVariables(moduleDataSources(ungrouped, dataSources))
+	if len(module.Groups) == 0 {
+		files = append(files, GeneratedFile{Path: filepath.Join(module.Dir, "generated_outputs.tf"), Content: renderOutputsFile(module.Outputs)})
+		return appendVariablesFile(files, module.Dir, variables)
+	}
+	// The interface module declares the variables of its groups too, and passes them on to their submodules.
+	var modules strings.Builder
+	for _, group := range module.Groups {
+		groupDir := filepath.Join(module.Dir, "modules", group)
+		groupVariables := lookupVariables(moduleDataSources(grouped[group], dataSources))
+		files = append(files, renderModuleSources(groupDir, grouped[group], dataSources, remoteState, schema)...)
+		files = append(files, GeneratedFile{Path: filepath.Join(groupDir, "generated_outputs.tf"), Content: renderOutputsFile(grouped[group])})
+		files = appendVariablesFile(files, groupDir, groupVariables)
+		fmt.Fprintf(&modules, "module \"%s\" {\n  source = \"./modules/%s\"\n", group, group)
+		for _, variable := range groupVariables {
+			fmt.Fprintf(&modules, "  %s = var.%s\n", variable, variable)
+			variables = appendUnique(variables, variable)
+		}
+		fmt.Fprintf(&modules, "}\n\n")
+	}
+	rootOutputs := renderOutputs(module.Outputs, func(output AnnotatedOutput) string {
+		if output.Annotation.Group != "" {
+			return "module." + output.Annotation.Group + "." + output.ExportedName()
+		}
+		return outputValue(output)
+	})
+	files = append(files,
+		GeneratedFile{Path: filepath.Join(module.Dir, "generated_modules.tf"), Content: modules.String()},
+		GeneratedFile{Path: filepath.Join(module.Dir, "generated_outputs.tf"), Content: rootOutputs},
+	)
+	return appendVariablesFile(files, module.Dir, variables)
+}
+
+// appendVariablesFile adds the variables of unresolved lookup values to a module, if it has any.
+func appendVariablesFile(files []GeneratedFile, dir string, variables []string) []GeneratedFile {
+	if len(variables) == 0 {
+		return files
+	}
+	return append(files, GeneratedFile{Path: filepath.Join(dir, "generated_variables.tf"), Content: renderVariablesFile(variables)})
+}
+
+// moduleDataSources are the data sources that outputs are read with.
+func moduleDataSources(outputs []AnnotatedOutput, dataSources []DataSourcePlan) []DataSourcePlan {
+	used := make(map[string]bool)
+	for _, output := range outputs {
+		if output.Annotation.Strategy != strategyRemoteState {
+			parts := strings.Split(output.Reference, ".")
+			used[parts[0]+"."+parts[1]] = true
+		}
+	}
+	var moduleDataSources []DataSourcePlan
+	for _, dataSource := range dataSources {
+		if used[dataSource.Type+"."+dataSource.Name] {
+			moduleDataSources = append(moduleDataSources, dataSource)
+		}
+	}
+	return moduleDataSources
+}
+
+// renderModuleSources renders the data sources and providers that outputs are read with into dir.
+func renderModuleSources(dir string, outputs []AnnotatedOutput, dataSources []DataSourcePlan, remoteState *Backend, schema ProviderSchema) []GeneratedFile {
+	usesRemoteState := false
+	var dataSourceOutputs []AnnotatedOutput
+	for _, output := range outputs {
+		if output.Annotation.Strategy == strategyRemoteState {
+			usesRemoteState = true
+			continue
+		}
+		dataSourceOutputs = append(dataSourceOutputs, output)
+	}
+	dataFile := renderDataFile(moduleDataSources(dataSourceOutputs, dataSources))
+	if usesRemoteState {
+		dataFile += renderRemoteState(*remoteState)
+	}
+	return []GeneratedFile{
+		{Path: filepath.Join(dir, "generated_data.tf"), Content: dataFile},
+		{Path: filepath.Join(dir, "generated_providers.tf"), Content: renderProviderFile(dataSourceOutputs, schema)},
+	}
+}
+
+func logSettings(project ProjectConfig, settings ProjectSettings) {
+	log.Printf("Effective settings for project %s:", project.Path)
+	if settings.Shell != "" {
+		log.Printf("  shell: %s", settings.Shell)
+	}
+	log.Printf("  command: %s", settings.Command)
+	for _, key := range sortedKeys(settings.Env) {
+		log.Printf("  env: %s (value hidden)", key)
+	}
+	for _, varFile := range settings.VarFiles {
+		log.Printf("  var file: %s", varFile)
+	}
+	if settings.Workspace != "" {
+		log.Printf("  workspace: %s", settings.Workspace)
+	}
+	if settings.Timeout > 0 {
+		log.Printf("  timeout: %s", settings.Timeout)
+	}
+}
+
+// writePlan writes the files of a plan and deletes the generated files that it no longer generates.
+func writePlan(fs afero.Fs, plan ProjectPlan, verbose bool) error {
+	stale, err := staleFiles(fs, plan)
+	if err != nil {
+		return err
+	}
+	for _, path := range stale {
+		if err := fs.Remove(path); err != nil {
+			return fmt.Errorf("failed to delete stale Terraform file %s: %v", path, err)
+		}
+		if verbose {
+			log.Printf("Deleted stale Terraform file: %s", path)
+		}
+		if err := removeEmptyGroupDir(fs, filepath.Dir(path)); err != nil {
+			return err
+		}
+	}
+	created := make(map[string]bool)
+	for _, file := range plan.Files {
+		if dir := filepath.Dir(file.Path); !created[dir] {
+			if err := createInterfaceDirectory(fs, dir, verbose); err != nil {
+				return err
+			}
+			created[dir] = true
+		}
+		if err := afero.WriteFile(fs, file.Path, []byte(file.Content), 0644); err != nil {
+			return fmt.Errorf("failed to write Terraform file %s: %v", file.Path, err)
+		}
+		if verbose {
+			log.Printf("Created Terraform file: %s", file.Path)
+		}
+	}
+	return nil
+}
+
+// removeEmptyGroupDir removes the submodule folder of a group that is no longer generated, and the modules folder
+// holding it once no group is left. Folders that still contain other files are kept.
+func removeEmptyGroupDir(fs afero.Fs, dir string) error {
+	for _, path := range []string{dir, filepath.Dir(dir)} {
+		if filepath.Base(filepath.Dir(dir)) != "modules" {
+			return nil
+		}
+		infos, err := afero.ReadDir(fs, path)
+		if err != nil || len(infos) > 0 {
+			return nil
+		}
+		if err := fs.Remove(path); err != nil {
+			return fmt.Errorf("failed to delete empty module folder %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+func applyPlan(w io.Writer, fs afero.Fs, plan ProjectPlan, verbose bool) error {
+	if err := printPlan(w, fs, plan, false); err != nil {
+		return err
+	}
+	return writePlan(fs, plan, verbose)
+}
+
+func fileStatus(fs afero.Fs, file GeneratedFile) (string, string, error) {
+	existing, err := afero.ReadFile(fs, file.Path)
+	if os.IsNotExist(err) {
+		return "create", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read %s: %v", file.Path, err)
+	}
+	if filepath.Base(file.Path) == manifestFileName && sameManifest(string(existing), file.Content) {
+		return "unchanged", string(existing), nil
+	}
+	if string(existing) != file.Content {
+		return "update", string(existing), nil
+	}
+	return "unchanged", string(existing), nil
+}
+
+func displayValue(lookup LookupValue) interface{} {
+	if lookup.Sensitive {
+		return "(sensitive value)"
+	}
+	return lookup.Value
+}
+
+func printPlan(w io.Writer, fs afero.Fs, plan ProjectPlan, showDiff bool) error {
+	fmt.Fprintf(w, "\033[1;33mProject: %s\033[0m\n", plan.FullPath)
+	if len(plan.Outputs) == 0 {
+		fmt.Fprintln(w, "No Annotated Outputs")
+		return nil
+	}
+	fmt.Fprintln(w, "Annotated Outputs:")
+	for _, output := range plan.Outputs {
+		fmt.Fprintf(w, "  %s (%s:%d) = %s\n", displayName(output), output.File, output.Line, output.Reference)
+	}
+	if len(plan.DataSources) > 0 {
+		fmt.Fprintln(w, "Data Sources:")
+		for _, dataSource := range plan.DataSources {
+			fmt.Fprintf(w, "\033[32m  data.%s.%s\033[0m\n", dataSource.Type, dataSource.Name)
+			for _, lookup := range dataSource.Lookups {
+				if lookup.Found {
+					fmt.Fprintf(w, "\033[32m    %s = %v\033[0m\n", lookup.Attribute, displayValue(lookup))
+				} else {
+					fmt.Fprintf(w, "\033[31m    %s = var.%s (not found in state)\033[0m\n", lookup.Attribute, lookupVariable(dataSource, lookup))
+				}
+			}
+		}
+	}
+	if plan.RemoteState != nil {
+		fmt.Fprintln(w, "Remote State:")
+		fmt.Fprintf(w, "\033[32m  data.terraform_remote_state.this (backend %s)\033[0m\n", plan.RemoteState.Type)
+	}
+	if len(plan.Interfaces) > 0 {
+		fmt.Fprintln(w, "Interfaces:")
+		for _, module := range plan.Interfaces {
+			fmt.Fprintf(w, "  %s: %s (%d outputs)\n", module.Audience, module.Dir, len(module.Outputs))
+			for _, group := range module.Groups {
+				fmt.Fprintf(w, "    group %s: %s\n", group, filepath.Join(module.Dir, "modules", group))
+			}
+		}
+	}
+	if len(plan.Skipped) > 0 {
+		fmt.Fprintln(w, "Skipped Outputs:")
+		for _, skipped := range plan.Skipped {
+			fmt.Fprintf(w, "\033[31m  %s (%s:%d): %s\033[0m\n", skipped.Output.Output, skipped.Output.File, skipped.Output.Line, skipped.Reason)
+		}
+	}
+	for _, problem := range plan.LintProblems {
+		fmt.Fprintf(w, "\033[31mLint: %s\033[0m\n", problem)
+	}
+	for _, warning := range plan.Warnings {
+		fmt.Fprintf(w, "\033[31mWarning: %s\033[0m\n", warning)
+	}
+	stale, err := staleFiles(fs, plan)
+	if err != nil {
+		return err
+	}
+	if len(plan.Files) > 0 || len(stale) > 0 {
+		fmt.Fprintln(w, "Files:")
+	}
+	for _, file := range plan.Files {
+		status, existing, err := fileStatus(fs, file)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "  %s: %s\n", status, file.Path)
+		if showDiff && status != "unchanged" {
+			diff, err := unifiedDiff(file.Path, existing, file.Content)
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(w, diff)
+		}
+	}
+	for _, path := range stale {
+		fmt.Fprintf(w, "\033[31m  delete: %s (no longer generated)\033[0m\n", path)
+		if showDiff {
+			existing, err := afero.ReadFile(fs, path)
+			if err != nil {
+				return err
+			}
+			diff, err := unifiedDiff(path, string(existing), "")
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(w, diff)
+		}
+	}
+	return nil
+}
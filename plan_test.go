@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	update                = flag.Bool("update", false, "Rewrite the golden files in testdata/golden")
+	producerCommitPattern = regexp.MustCompile(`\s*"producer_commit": "[0-9a-f]*",`)
+)
+
+// TestPlanProjectWithFixtures runs the whole pipeline for examples/simple against the recorded terraform output in
+// testdata/fixtures and compares the generated files with testdata/golden.
+func TestPlanProjectWithFixtures(t *testing.T) {
+	currentDir, err := os.Getwd()
+	assert.Nil(t, err)
+	fs := afero.NewOsFs()
+	runner := FixtureRunner{Fs: fs, Dir: filepath.Join(currentDir, "testdata", "fixtures"), BaseDir: currentDir}
+	project := ProjectConfig{Path: "examples/simple"}
+
+	plan, err := planProject(context.Background(), runner, fs, project, currentDir, ProjectSettings{Command: "terraform"}, false)
+	assert.Nil(t, err)
+	assert.Len(t, plan.Outputs, 6)
+	assert.Len(t, plan.DataSources, 1)
+	assert.Len(t, plan.Skipped, 3)
+	assert.Len(t, plan.Files, 5)
+
+	for _, file := range plan.Files {
+		golden := filepath.Join("testdata", "golden", "simple", filepath.Base(file.Path))
+		// The commit of this repository changes with every commit, so it is left out of the golden manifest.
+		content := producerCommitPattern.ReplaceAllString(file.Content, "")
+		if *update {
+			assert.Nil(t, os.MkdirAll(filepath.Dir(golden), 0755))
+			assert.Nil(t, os.WriteFile(golden, []byte(content), 0644))
+			continue
+		}
+		expected, err := os.ReadFile(golden)
+		assert.Nil(t, err)
+		assert.Equal(t, string(expected), content, golden)
+	}
+}
+
+func TestFixtureRunner(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/fixtures/stacks/dns/providers_schema.json", []byte(`{"format_version":"1.0"}`), 0644)
+	runner := FixtureRunner{Fs: fs, Dir: "/fixtures", BaseDir: "/repo"}
+
+	output, err := runner.Run(context.Background(), ProjectSettings{}, "/repo/stacks/dns", "providers", "schema", "-json")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"format_version":"1.0"}`, string(output))
+
+	_, err = runner.Run(context.Background(), ProjectSettings{}, "/repo/stacks/dns", "show", "-json")
+	assert.ErrorContains(t, err, `no fixture for "show -json"`)
+
+	recorder := RecordingRunner{Runner: runner, Fs: fs, Dir: "/recorded", BaseDir: "/repo"}
+	_, err = recorder.Run(context.Background(), ProjectSettings{}, "/repo/stacks/dns", "providers", "schema", "-json")
+	assert.Nil(t, err)
+	recorded, err := afero.ReadFile(fs, "/recorded/stacks/dns/providers_schema.json")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"format_version":"1.0"}`, string(recorded))
+}
+
+func TestPlanGroups(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	state, err := os.ReadFile("testdata/fixtures/examples/simple/show.json")
+	assert.Nil(t, err)
+	schema, err := os.ReadFile("testdata/fixtures/examples/simple/providers_schema.json")
+	assert.Nil(t, err)
+	afero.WriteFile(fs, "/fixtures/show.json", state, 0644)
+	afero.WriteFile(fs, "/fixtures/providers_schema.json", schema, 0644)
+	afero.WriteFile(fs, "/project/main.tf", []byte(`# @public(group="files", description="Path of the file")
+output "local_file_path" {
+  value = local_file.my_local_file.filename
+}
+
+# @public(group="files", name="file_contents")
+output "local_file_contents" {
+  value = local_file.my_local_file.content
+}
+
+# @public
+output "local_file_id" {
+  value = local_file.my_local_file.id
+}
+`), 0644)
+	runner := FixtureRunner{Fs: fs, Dir: "/fixtures", BaseDir: "/project"}
+
+	plan, err := planProject(context.Background(), runner, fs, ProjectConfig{Path: "."}, "/project", ProjectSettings{Command: "terraform"}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"files"}, plan.Interfaces[0].Groups)
+	files := make(map[string]string)
+	for _, file := range plan.Files {
+		files[file.Path] = file.Content
+	}
+	assert.Len(t, files, 9)
+	assert.Contains(t, files["/project/interface/modules/files/generated_data.tf"], `data "local_file" "my_local_file"`)
+	assert.Contains(t, files["/project/interface/modules/files/generated_providers.tf"], `source = "registry.terraform.io/hashicorp/local"`)
+	assert.Equal(t, `output "local_file_path" {
+  description = "Path of the file"
+  value = data.local_file.my_local_file.filename
+}
+
+output "file_contents" {
+  value = data.local_file.my_local_file.content
+}
+
+`, files["/project/interface/modules/files/generated_outputs.tf"])
+	assert.Equal(t, "module \"files\" {\n  source = \"./modules/files\"\n}\n\n", files["/project/interface/generated_modules.tf"])
+	assert.Equal(t, `output "local_file_path" {
+  description = "Path of the file"
+  value = module.files.local_file_path
+}
+
+output "file_contents" {
+  value = module.files.file_contents
+}
+
+output "local_file_id" {
+  value = data.local_file.my_local_file.id
+}
+
+`, files["/project/interface/generated_outputs.tf"])
+
+	assert.Nil(t, writePlan(fs, plan, false))
+	exists, _ := afero.Exists(fs, "/project/interface/modules/files/generated_outputs.tf")
+	assert.True(t, exists)
+}
+
+func TestPrintPlan(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/project/interface/generated_outputs.tf", []byte("output \"old\" {}\n"), 0644)
+	afero.WriteFile(fs, "/project/interface/generated_providers.tf", []byte("provider \"provider1\" {}\n"), 0644)
+	afero.WriteFile(fs, "/project/interface-internal/generated_outputs.tf", []byte("output \"internal\" {}\n"), 0644)
+	plan := ProjectPlan{
+		FullPath:     "/project",
+		InterfaceDir: "/project/interface",
+		Outputs:      []AnnotatedOutput{{File: "main.tf", Line: 2, Output: "output1", Reference: "data.resource1.instance1.attribute1"}},
+		DataSources: []DataSourcePlan{{Type: "resource1", Name: "instance1", Lookups: []LookupValue{
+			{Attribute: "attribute1", Value: "value1", Found: true},
+			{Attribute: "attribute2"},
+		}}},
+		Skipped:  []SkippedOutput{{Output: AnnotatedOutput{File: "main.tf", Line: 7, Output: "output2"}, Reason: "not a data source"}},
+		Warnings: []string{"something odd"},
+		Files: []GeneratedFile{
+			{Path: "/project/interface/generated_data.tf", Content: "data \"resource1\" \"instance1\" {}\n"},
+			{Path: "/project/interface/generated_outputs.tf", Content: "output \"output1\" {}\n"},
+			{Path: "/project/interface/generated_providers.tf", Content: "provider \"provider1\" {}\n"},
+		},
+	}
+
+	var out bytes.Buffer
+	assert.Nil(t, printPlan(&out, fs, plan, true))
+	for _, line := range []string{
+		"Project: /project",
+		"  output1 (main.tf:2) = data.resource1.instance1.attribute1\n",
+		"    attribute1 = value1",
+		"    attribute2 = var.resource1_instance1_attribute2 (not found in state)",
+		"  output2 (main.tf:7): not a data source",
+		"Warning: something odd",
+		"  create: /project/interface/generated_data.tf\n",
+		"+data \"resource1\" \"instance1\" {}",
+		"  update: /project/interface/generated_outputs.tf\n",
+		"-output \"old\" {}",
+		"+output \"output1\" {}",
+		"  unchanged: /project/interface/generated_providers.tf\n",
+		"  delete: /project/interface-internal/generated_outputs.tf (no longer generated)",
+		"-output \"internal\" {}",
+	} {
+		assert.Contains(t, out.String(), line)
+	}
+	assert.NotContains(t, out.String(), "provider \"provider1\" {}")
+
+	out.Reset()
+	assert.Nil(t, printPlan(&out, fs, plan, false))
+	assert.Contains(t, out.String(), "  update: /project/interface/generated_outputs.tf\n")
+	assert.NotContains(t, out.String(), "+output \"output1\" {}")
+
+	out.Reset()
+	assert.Nil(t, printPlan(&out, fs, ProjectPlan{FullPath: "/empty"}, true))
+	assert.Contains(t, out.String(), "No Annotated Outputs")
+}
+
+func TestLogSettingsHidesEnvValues(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+	logSettings(ProjectConfig{Path: "dns"}, ProjectSettings{Shell: "bash", Command: "tofu", Env: map[string]string{"TF_VAR_password": "hunter2"}})
+	assert.Contains(t, out.String(), "env: TF_VAR_password (value hidden)")
+	assert.NotContains(t, out.String(), "hunter2")
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	readmeFileName    = "README.md"
+	readmeBeginMarker = "<!-- BEGIN_TF_INTERFACES -->"
+	readmeEndMarker   = "<!-- END_TF_INTERFACES -->"
+)
+
+// renderReadme renders the README of an interface module. Only the part between the markers is generated, so text
+// written around it in an existing README is kept.
+func renderReadme(fs afero.Fs, module InterfacePlan, manifest Manifest, currentDir string) (GeneratedFile, error) {
+	path := filepath.Join(module.Dir, readmeFileName)
+	generated := readmeMarkerBlock(module, manifest, currentDir)
+	existing, found, err := fsReader(fs)(path)
+	if err != nil {
+		return GeneratedFile{}, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if !found {
+		return GeneratedFile{Path: path, Content: generated}, nil
+	}
+	begins := strings.Count(existing, readmeBeginMarker)
+	ends := strings.Count(existing, readmeEndMarker)
+	if begins == 0 && ends == 0 {
+		return GeneratedFile{Path: path, Content: strings.TrimRight(existing, "\n") + "\n\n" + generated}, nil
+	}
+	// Anything but one pair of markers in order would make the generated block swallow hand-written text.
+	begin := strings.Index(existing, readmeBeginMarker)
+	end := strings.Index(existing, readmeEndMarker)
+	switch {
+	case begins == 0:
+		return GeneratedFile{}, fmt.Errorf("%s has %s but no %s", path, readmeEndMarker, readmeBeginMarker)
+	case ends == 0:
+		return GeneratedFile{}, fmt.Errorf("%s has %s but no %s", path, readmeBeginMarker, readmeEndMarker)
+	case begins > 1 || ends > 1:
+		return GeneratedFile{}, fmt.Errorf("%s has %d %s and %d %s markers, expected one of each", path, begins, readmeBeginMarker, ends, readmeEndMarker)
+	case end < begin:
+		return GeneratedFile{}, fmt.Errorf("%s has %s before %s", path, readmeEndMarker, readmeBeginMarker)
+	}
+	content := existing[:begin] + strings.TrimSuffix(generated, "\n") + existing[end+len(readmeEndMarker):]
+	return GeneratedFile{Path: path, Content: content}, nil
+}
+
+func readmeMarkerBlock(module InterfacePlan, manifest Manifest, currentDir string) string {
+	projectName := filepath.Base(filepath.Join(currentDir, manifest.Project))
+	source := "./" + filepath.ToSlash(relativeTo(currentDir, module.Dir))
+	var b strings.Builder
+	fmt.Fprintln(&b, readmeBeginMarker)
+	if module.Audience == audiencePublic {
+		fmt.Fprintf(&b, "# Interface of %s\n\n", manifest.Project)
+	} else {
+		fmt.Fprintf(&b, "# Interface of %s for %s\n\n", manifest.Project, module.Audience)
+	}
+	fmt.Fprintf(&b, "Generated by tf-interfaces from the annotated outputs of `%s`. It reads them with data sources, so\n", manifest.Project)
+	fmt.Fprintln(&b, "using it does not need access to that project's state. Edit the text outside the markers only.")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "## Usage")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "```hcl")
+	fmt.Fprintf(&b, "module \"%s\" {\n", strings.ReplaceAll(projectName, "-", "_"))
+	fmt.Fprintf(&b, "  source = %q\n", source)
+	fmt.Fprintln(&b, "}")
+	fmt.Fprintln(&b, "```")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "The source is relative to the directory of the tf-interfaces config. From another repository, use a git")
+	fmt.Fprintln(&b, "address.")
+	if len(module.Groups) > 0 {
+		fmt.Fprintln(&b)
+		fmt.Fprintln(&b, "Each group of outputs can also be used on its own:")
+		fmt.Fprintln(&b)
+		fmt.Fprintln(&b, "| Group | Source |")
+		fmt.Fprintln(&b, "|-------|--------|")
+		for _, group := range module.Groups {
+			fmt.Fprintf(&b, "| %s | `%s/modules/%s` |\n", group, source, group)
+		}
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "## Outputs")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "| Name | Description | Type | Sensitive | Stability |")
+	fmt.Fprintln(&b, "|------|-------------|------|-----------|-----------|")
+	for _, output := range manifest.Outputs {
+		stability := output.Stability
+		if output.Deprecation != "" {
+			stability += ": " + output.Deprecation
+		}
+		sensitive := "no"
+		if output.Sensitive {
+			sensitive = "yes"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | `%s` | %s | %s |\n", output.Name, markdownCell(output.Description), typeExpression(output.Type), sensitive, markdownCell(stability))
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "## Providers")
+	fmt.Fprintln(&b)
+	if len(manifest.Providers) == 0 {
+		fmt.Fprintln(&b, "None.")
+	} else {
+		fmt.Fprintln(&b, "| Source | Version | Constraints |")
+		fmt.Fprintln(&b, "|--------|---------|-------------|")
+		for _, provider := range manifest.Providers {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", provider.Source, provider.Version, markdownCell(provider.Constraints))
+		}
+	}
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "## Data sources")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "| Data source | Looked up by | Outputs |")
+	fmt.Fprintln(&b, "|-------------|--------------|---------|")
+	dataSources := make(map[string][]string)
+	lookups := make(map[string][]string)
+	for _, output := range manifest.Outputs {
+		dataSources[output.DataSource] = append(dataSources[output.DataSource], "`"+output.Name+"`")
+		lookups[output.DataSource] = output.LookupAttributes
+	}
+	var addresses []string
+	for address := range dataSources {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		lookedUpBy := strings.Join(lookups[address], ", ")
+		if address == "data.terraform_remote_state.this" {
+			lookedUpBy = "the producer's backend"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s |\n", address, lookedUpBy, strings.Join(dataSources[address], ", "))
+	}
+	fmt.Fprintln(&b, readmeEndMarker)
+	return b.String()
+}
+
+// typeExpression writes a type from a provider schema or the state, such as ["list","string"], as Terraform writes
+// it in a variable's type.
+func typeExpression(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		if value == "dynamic" {
+			return "any"
+		}
+		return value
+	case []interface{}:
+		if len(value) != 2 {
+			break
+		}
+		kind, _ := value[0].(string)
+		switch kind {
+		case "list", "set", "map":
+			return fmt.Sprintf("%s(%s)", kind, typeExpression(value[1]))
+		case "object":
+			attributes, _ := value[1].(map[string]interface{})
+			var names []string
+			for name := range attributes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			var fields []string
+			for _, name := range names {
+				fields = append(fields, name+" = "+typeExpression(attributes[name]))
+			}
+			return "object({" + strings.Join(fields, ", ") + "})"
+		case "tuple":
+			elements, _ := value[1].([]interface{})
+			var types []string
+			for _, element := range elements {
+				types = append(types, typeExpression(element))
+			}
+			return "tuple([" + strings.Join(types, ", ") + "])"
+		}
+	}
+	return "any"
+}
+
+func markdownCell(value string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(value, "|", "\\|"), "\n", " ")
+}
+
+func relativeTo(base string, path string) string {
+	relativePath, err := filepath.Rel(base, path)
+	if err != nil {
+		return path
+	}
+	return relativePath
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderReadme(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	module := InterfacePlan{Audience: "team-a", Dir: "/repo/stacks/network/interface-team-a", Groups: []string{"dns"}}
+	manifest := Manifest{
+		Project: "stacks/network",
+		Outputs: []ManifestOutput{
+			{Name: "zone_id", Description: "Zone | public", Type: "string", DataSource: "data.aws_route53_zone.main", LookupAttributes: []string{"name"}, Group: "dns", Stability: stabilityDeprecated, Deprecation: "use zone_ids"},
+			{Name: "vpc", Type: []interface{}{"object", map[string]interface{}{"id": "string", "cidrs": []interface{}{"list", "string"}}}, Sensitive: true, DataSource: "data.terraform_remote_state.this"},
+		},
+	}
+
+	readme, err := renderReadme(fs, module, manifest, "/repo")
+	assert.Nil(t, err)
+	assert.Equal(t, "/repo/stacks/network/interface-team-a/README.md", readme.Path)
+	assert.Contains(t, readme.Content, "# Interface of stacks/network for team-a\n")
+	assert.Contains(t, readme.Content, "module \"network\" {\n  source = \"./stacks/network/interface-team-a\"\n}")
+	assert.Contains(t, readme.Content, "| dns | `./stacks/network/interface-team-a/modules/dns` |\n")
+	assert.Contains(t, readme.Content, "| `zone_id` | Zone \\| public | `string` | no | deprecated: use zone_ids |\n")
+	assert.Contains(t, readme.Content, "| `vpc` |  | `object({cidrs = list(string), id = string})` | yes |  |\n")
+	assert.Contains(t, readme.Content, "| `data.terraform_remote_state.this` | the producer's backend | `vpc` |\n")
+	assert.Contains(t, readme.Content, "## Providers\n\nNone.\n")
+
+	afero.WriteFile(fs, readme.Path, []byte("# Network\n\nHand-written.\n\n"+readmeBeginMarker+"\nold\n"+readmeEndMarker+"\n\n## Support\n\n#network\n"), 0644)
+	updated, err := renderReadme(fs, module, manifest, "/repo")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(updated.Content, "# Network\n\nHand-written.\n\n"+readmeBeginMarker+"\n# Interface of"))
+	assert.True(t, strings.HasSuffix(updated.Content, "`vpc` |\n"+readmeEndMarker+"\n\n## Support\n\n#network\n"))
+	assert.NotContains(t, updated.Content, "old")
+
+	afero.WriteFile(fs, readme.Path, []byte("# Network\n"), 0644)
+	updated, err = renderReadme(fs, module, manifest, "/repo")
+	assert.Nil(t, err)
+	assert.Equal(t, "# Network\n\n"+readme.Content, updated.Content)
+
+	tests := []struct {
+		name     string
+		existing string
+		err      string
+	}{
+		{"missing end", "# Network\n" + readmeBeginMarker + "\nold\n", readme.Path + " has " + readmeBeginMarker + " but no " + readmeEndMarker},
+		{"missing begin", "# Network\nold\n" + readmeEndMarker + "\n", readme.Path + " has " + readmeEndMarker + " but no " + readmeBeginMarker},
+		{"duplicated begin", readmeBeginMarker + "\nold\n" + readmeBeginMarker + "\nold\n" + readmeEndMarker + "\n", readme.Path + " has 2 " + readmeBeginMarker + " and 1 " + readmeEndMarker + " markers, expected one of each"},
+		{"duplicated end", readmeBeginMarker + "\nold\n" + readmeEndMarker + "\nold\n" + readmeEndMarker + "\n", readme.Path + " has 1 " + readmeBeginMarker + " and 2 " + readmeEndMarker + " markers, expected one of each"},
+		{"end before begin", readmeEndMarker + "\nHand-written.\n" + readmeBeginMarker + "\n", readme.Path + " has " + readmeEndMarker + " before " + readmeBeginMarker},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			afero.WriteFile(fs, readme.Path, []byte(test.existing), 0644)
+			_, err := renderReadme(fs, module, manifest, "/repo")
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// Backend is the backend block of a project, which the remote_state strategy reads the project's outputs through.
+type Backend struct {
+	Type   string
+	Config []BackendSetting
+	// Workspace is the workspace the project is run in, whose state is read instead of the default workspace's.
+	Workspace string
+}
+
+// BackendSetting is an argument of a backend block. Value is the HCL expression as written, e.g. "\"my-bucket\"".
+type BackendSetting struct {
+	Name  string
+	Value string
+}
+
+var (
+	backendPattern        = regexp.MustCompile(`^\s*backend\s+"([^"]+)"\s*\{`)
+	backendSettingPattern = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_-]*)\s*=\s*(.+?)\s*$`)
+	backendBlockPattern   = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_-]*)\s*=?\s*\{`)
+	// literalPattern matches a string without interpolation, a number, a bool, or a list of strings.
+	literalPattern = regexp.MustCompile(`^("([^"\\$]|\\.|\$[^{])*"|-?[0-9][0-9.]*|true|false|\[\s*("([^"\\$]|\\.|\$[^{])*"\s*,?\s*)*\])$`)
+)
+
+// requiredBackendSettings are the arguments without which the common backends cannot find a state. A block that
+// leaves them out is completed with -backend-config at init, which terraform_remote_state cannot do.
+var requiredBackendSettings = map[string][]string{
+	"azurerm": {"storage_account_name", "container_name", "key"},
+	"gcs":     {"bucket"},
+	"s3":      {"bucket", "key"},
+}
+
+// findBackend returns the backend block in the .tf files of moduleDir, or nil if there is none. A block that cannot be
+// copied into terraform_remote_state as it is, because it is partial, refers to anything or has nested blocks, is an
+// error.
+func findBackend(fs afero.Fs, moduleDir string) (*Backend, error) {
+	files, err := terraformFiles(fs, moduleDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		content, err := afero.ReadFile(fs, file)
+		if err != nil {
+			return nil, err
+		}
+		var backend *Backend
+		depth := 0
+		scanner := bufio.NewScanner(strings.NewReader(string(content)))
+		for scanner.Scan() {
+			line := scanner.Text()
+			if backend == nil {
+				if matches := backendPattern.FindStringSubmatch(line); matches != nil {
+					backend = &Backend{Type: matches[1]}
+					depth = strings.Count(line, "{") - strings.Count(line, "}")
+					if depth <= 0 {
+						return backend, validateBackend(*backend, file)
+					}
+				}
+				continue
+			}
+			if depth == 1 {
+				if matches := backendBlockPattern.FindStringSubmatch(line); matches != nil {
+					return nil, fmt.Errorf("backend %q in %s has a nested %s block, which terraform_remote_state cannot be configured with", backend.Type, file, matches[1])
+				}
+				if matches := backendSettingPattern.FindStringSubmatch(line); matches != nil {
+					backend.Config = append(backend.Config, BackendSetting{Name: matches[1], Value: matches[2]})
+				}
+			}
+			depth += strings.Count(line, "{") - strings.Count(line, "}")
+			if depth <= 0 {
+				return backend, validateBackend(*backend, file)
+			}
+		}
+		if backend != nil {
+			return nil, fmt.Errorf("unterminated backend block in %s", file)
+		}
+	}
+	return nil, nil
+}
+
+// validateBackend checks that the backend block in file holds all of its configuration as literals, so that the
+// generated terraform_remote_state reads the same state.
+func validateBackend(backend Backend, file string) error {
+	if len(backend.Config) == 0 && backend.Type != "local" {
+		return fmt.Errorf("backend %q in %s has no arguments; a backend configured with -backend-config cannot be read with terraform_remote_state", backend.Type, file)
+	}
+	configured := make(map[string]bool)
+	for _, setting := range backend.Config {
+		if !literalPattern.MatchString(setting.Value) {
+			return fmt.Errorf("argument %s of backend %q in %s is not a literal value: %s", setting.Name, backend.Type, file, setting.Value)
+		}
+		configured[setting.Name] = true
+	}
+	for _, name := range requiredBackendSettings[backend.Type] {
+		if !configured[name] {
+			return fmt.Errorf("backend %q in %s has no %s argument; a backend configured with -backend-config cannot be read with terraform_remote_state", backend.Type, file, name)
+		}
+	}
+	return nil
+}
+
+func renderRemoteState(backend Backend) string {
+	var b strings.Builder
+	fmt.Fprintln(&b, "data \"terraform_remote_state\" \"this\" {")
+	fmt.Fprintf(&b, "  backend = %q\n", backend.Type)
+	if backend.Workspace != "" {
+		fmt.Fprintf(&b, "  workspace = %q\n", backend.Workspace)
+	}
+	if len(backend.Config) > 0 {
+		fmt.Fprintln(&b, "  config = {")
+		for _, setting := range backend.Config {
+			fmt.Fprintf(&b, "    %s = %s\n", setting.Name, setting.Value)
+		}
+		fmt.Fprintln(&b, "  }")
+	}
+	fmt.Fprintf(&b, "}\n\n")
+	return b.String()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+type Report struct {
+	Projects []ProjectReport `json:"projects"`
+}
+
+type ProjectReport struct {
+	Path           string            `json:"path"`
+	InterfaceDir   string            `json:"interface_dir"`
+	Error          string            `json:"error,omitempty"`
+	Outputs        []OutputReport    `json:"outputs"`
+	Warnings       []string          `json:"warnings"`
+	LintProblems   []string          `json:"lint_problems"`
+	Interfaces     []InterfaceReport `json:"interfaces"`
+	GeneratedFiles []FileReport      `json:"generated_files"`
+}
+
+type InterfaceReport struct {
+	Audience string   `json:"audience"`
+	Dir      string   `json:"dir"`
+	Outputs  []string `json:"outputs"`
+	Groups   []string `json:"groups"`
+}
+
+type OutputReport struct {
+	Name        string            `json:"name"`
+	File        string            `json:"file"`
+	Line        int               `json:"line"`
+	Reference   string            `json:"reference"`
+	ExportedAs  string            `json:"exported_as"`
+	Description string            `json:"description,omitempty"`
+	Group       string            `json:"group,omitempty"`
+	Strategy    string            `json:"strategy"`
+	Audiences   []string          `json:"audiences"`
+	Stability   string            `json:"stability,omitempty"`
+	Deprecation string            `json:"deprecation,omitempty"`
+	Since       string            `json:"since,omitempty"`
+	Matched     bool              `json:"matched"`
+	SkipReason  string            `json:"skip_reason,omitempty"`
+	DataSource  *DataSourceReport `json:"data_source,omitempty"`
+}
+
+type DataSourceReport struct {
+	Address string         `json:"address"`
+	Lookups []LookupReport `json:"lookups"`
+}
+
+type LookupReport struct {
+	Attribute string      `json:"attribute"`
+	Value     interface{} `json:"value"`
+	Found     bool        `json:"found"`
+	Sensitive bool        `json:"sensitive"`
+}
+
+type FileReport struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+}
+
+// newProjectReport describes a plan, or the error that stopped it, in the shape of the JSON report. File statuses are
+// relative to what is on disk before anything is written.
+func newProjectReport(fs afero.Fs, plan ProjectPlan, planErr error) (ProjectReport, error) {
+	report := ProjectReport{
+		Path:           plan.Project.Path,
+		InterfaceDir:   plan.InterfaceDir,
+		Outputs:        []OutputReport{},
+		Warnings:       []string{},
+		LintProblems:   []string{},
+		Interfaces:     []InterfaceReport{},
+		GeneratedFiles: []FileReport{},
+	}
+	if planErr != nil {
+		report.Error = planErr.Error()
+		return report, nil
+	}
+	report.Warnings = append(report.Warnings, plan.Warnings...)
+	report.LintProblems = append(report.LintProblems, plan.LintProblems...)
+	skipped := make(map[string]string)
+	for _, skippedOutput := range plan.Skipped {
+		skipped[skippedOutput.Output.Output] = skippedOutput.Reason
+	}
+	dataSources := make(map[string]DataSourcePlan)
+	for _, dataSource := range plan.DataSources {
+		dataSources[dataSource.Type+"."+dataSource.Name] = dataSource
+	}
+	for _, output := range plan.Outputs {
+		outputReport := OutputReport{
+			Name:        output.Output,
+			File:        output.File,
+			Line:        output.Line,
+			Reference:   output.Reference,
+			ExportedAs:  output.ExportedName(),
+			Description: output.Annotation.Description,
+			Group:       output.Annotation.Group,
+			Strategy:    output.Annotation.Strategy,
+			Since:       output.Annotation.Since,
+			Audiences:   output.Annotation.Audiences,
+			Stability:   output.Annotation.Stability,
+			Deprecation: output.Annotation.Deprecation,
+		}
+		if reason, isSkipped := skipped[output.Output]; isSkipped {
+			outputReport.SkipReason = reason
+		} else if output.Annotation.Strategy == strategyRemoteState {
+			outputReport.Matched = true
+			outputReport.DataSource = &DataSourceReport{Address: "data.terraform_remote_state.this", Lookups: []LookupReport{}}
+		} else {
+			outputReport.Matched = true
+			parts := strings.Split(output.Reference, ".")
+			if dataSource, exists := dataSources[parts[0]+"."+parts[1]]; exists {
+				outputReport.DataSource = newDataSourceReport(dataSource)
+			}
+		}
+		report.Outputs = append(report.Outputs, outputReport)
+	}
+	for _, module := range plan.Interfaces {
+		interfaceReport := InterfaceReport{Audience: module.Audience, Dir: module.Dir, Outputs: []string{}, Groups: append([]string{}, module.Groups...)}
+		for _, output := range module.Outputs {
+			interfaceReport.Outputs = append(interfaceReport.Outputs, output.ExportedName())
+		}
+		report.Interfaces = append(report.Interfaces, interfaceReport)
+	}
+	for _, file := range plan.Files {
+		status, _, err := fileStatus(fs, file)
+		if err != nil {
+			return report, err
+		}
+		report.GeneratedFiles = append(report.GeneratedFiles, FileReport{Path: file.Path, Status: status})
+	}
+	stale, err := staleFiles(fs, plan)
+	if err != nil {
+		return report, err
+	}
+	for _, path := range stale {
+		report.GeneratedFiles = append(report.GeneratedFiles, FileReport{Path: path, Status: "delete"})
+	}
+	return report, nil
+}
+
+func newDataSourceReport(dataSource DataSourcePlan) *DataSourceReport {
+	report := &DataSourceReport{
+		Address: "data." + dataSource.Type + "." + dataSource.Name,
+		Lookups: []LookupReport{},
+	}
+	for _, lookup := range dataSource.Lookups {
+		report.Lookups = append(report.Lookups, LookupReport{
+			Attribute: lookup.Attribute,
+			Value:     displayValue(lookup),
+			Found:     lookup.Found,
+			Sensitive: lookup.Sensitive,
+		})
+	}
+	return report
+}
+
+func writeReport(w io.Writer, report Report) error {
+	if report.Projects == nil {
+		report.Projects = []ProjectReport{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteReport(t *testing.T) {
+	command := fakeTerraform(t, t.TempDir())
+	currentDir := t.TempDir()
+	os.MkdirAll(filepath.Join(currentDir, "project"), 0755)
+	afero.WriteFile(afero.NewOsFs(), filepath.Join(currentDir, "project", "main.tf"), []byte(testProject+`
+resource "resource3" "instance3" {}
+# @public
+output "output4" {
+  value = resource3.instance3.attribute1
+}
+`), 0644)
+
+	project := ProjectConfig{Path: "project"}
+	plan, err := planProject(context.Background(), ExecRunner{}, afero.NewOsFs(), project, currentDir, ProjectSettings{Shell: "bash", Command: command}, false)
+	assert.Nil(t, err)
+	passing, err := newProjectReport(afero.NewOsFs(), plan, nil)
+	assert.Nil(t, err)
+	failing, err := newProjectReport(afero.NewOsFs(), ProjectPlan{Project: ProjectConfig{Path: "missing"}}, errors.New("no such project"))
+	assert.Nil(t, err)
+
+	var out bytes.Buffer
+	assert.Nil(t, writeReport(&out, Report{Projects: []ProjectReport{passing, failing}}))
+	var report map[string][]map[string]interface{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &report))
+	if !assert.Len(t, report["projects"], 2) {
+		return
+	}
+
+	interfaceDir := filepath.Join(currentDir, "project", "interface")
+	expected := map[string]interface{}{
+		"path":          "project",
+		"interface_dir": interfaceDir,
+		"outputs": []interface{}{
+			map[string]interface{}{
+				"name": "output1", "file": "main.tf", "line": float64(9), "reference": "resource1.instance1.attribute1",
+				"exported_as": "output1", "strategy": "data_source", "audiences": []interface{}{"public"}, "matched": true,
+				"data_source": map[string]interface{}{
+					"address": "data.resource1.instance1",
+					"lookups": []interface{}{},
+				},
+			},
+			map[string]interface{}{
+				"name": "output2", "file": "main.tf", "line": float64(13), "reference": "resource2.instance2.attribute1",
+				"exported_as": "output2", "strategy": "data_source", "audiences": []interface{}{"public"}, "matched": true,
+				"data_source": map[string]interface{}{
+					"address": "data.resource2.instance2",
+					"lookups": []interface{}{},
+				},
+			},
+			map[string]interface{}{
+				"name": "output4", "file": "main.tf", "line": float64(23), "reference": "resource3.instance3.attribute1",
+				"exported_as": "output4", "strategy": "data_source", "audiences": []interface{}{"public"}, "matched": false,
+				"skip_reason": "no data source matches resource type resource3",
+			},
+		},
+		"warnings":      []interface{}{},
+		"lint_problems": []interface{}{},
+		"interfaces": []interface{}{
+			map[string]interface{}{"audience": "public", "dir": interfaceDir, "outputs": []interface{}{"output1", "output2"}, "groups": []interface{}{}},
+		},
+		"generated_files": []interface{}{
+			map[string]interface{}{"path": filepath.Join(interfaceDir, "generated_data.tf"), "status": "create"},
+			map[string]interface{}{"path": filepath.Join(interfaceDir, "generated_providers.tf"), "status": "create"},
+			map[string]interface{}{"path": filepath.Join(interfaceDir, "generated_outputs.tf"), "status": "create"},
+			map[string]interface{}{"path": filepath.Join(interfaceDir, "generated_manifest.json"), "status": "create"},
+			map[string]interface{}{"path": filepath.Join(interfaceDir, "README.md"), "status": "create"},
+		},
+	}
+	assert.Equal(t, expected, report["projects"][0])
+
+	assert.Equal(t, map[string]interface{}{
+		"path":            "missing",
+		"interface_dir":   "",
+		"error":           "no such project",
+		"outputs":         []interface{}{},
+		"warnings":        []interface{}{},
+		"lint_problems":   []interface{}{},
+		"interfaces":      []interface{}{},
+		"generated_files": []interface{}{},
+	}, report["projects"][1])
+}
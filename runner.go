@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// Runner runs a terraform/tofu subcommand, such as "show -json", for a project and returns its stdout.
+type Runner interface {
+	Run(ctx context.Context, settings ProjectSettings, projectPath string, args ...string) ([]byte, error)
+}
+
+type ExecRunner struct {
+	Verbose bool
+}
+
+func (r ExecRunner) Run(ctx context.Context, settings ProjectSettings, projectPath string, args ...string) ([]byte, error) {
+	return runTerraform(ctx, settings, projectPath, r.Verbose, args...)
+}
+
+// FixtureRunner replays recorded output instead of running anything. The fixture for a command in a project is
+// Dir/<project path relative to BaseDir>/<command words joined by "_">.json, e.g. stacks/network/providers_schema.json.
+type FixtureRunner struct {
+	Fs      afero.Fs
+	Dir     string
+	BaseDir string
+}
+
+func (r FixtureRunner) Run(ctx context.Context, settings ProjectSettings, projectPath string, args ...string) ([]byte, error) {
+	path, err := fixturePath(r.Dir, r.BaseDir, projectPath, args)
+	if err != nil {
+		return nil, err
+	}
+	output, err := afero.ReadFile(r.Fs, path)
+	if err != nil {
+		return nil, fmt.Errorf("no fixture for %q: %v", strings.Join(args, " "), err)
+	}
+	return output, nil
+}
+
+// RecordingRunner runs commands with another runner and saves their output where a FixtureRunner will look for it.
+type RecordingRunner struct {
+	Runner  Runner
+	Fs      afero.Fs
+	Dir     string
+	BaseDir string
+}
+
+func (r RecordingRunner) Run(ctx context.Context, settings ProjectSettings, projectPath string, args ...string) ([]byte, error) {
+	output, err := r.Runner.Run(ctx, settings, projectPath, args...)
+	if err != nil {
+		return nil, err
+	}
+	path, err := fixturePath(r.Dir, r.BaseDir, projectPath, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %v", err)
+	}
+	if err := afero.WriteFile(r.Fs, path, output, 0644); err != nil {
+		return nil, fmt.Errorf("failed to record fixture %s: %v", path, err)
+	}
+	return output, nil
+}
+
+func fixturePath(dir string, baseDir string, projectPath string, args []string) (string, error) {
+	relativePath, err := filepath.Rel(baseDir, projectPath)
+	if err != nil {
+		return "", err
+	}
+	var words []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			words = append(words, arg)
+		}
+	}
+	return filepath.Join(dir, relativePath, strings.Join(words, "_")+".json"), nil
+}
+
+type CommandError struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	message := fmt.Sprintf("command %q failed", e.Command)
+	if e.ExitCode >= 0 {
+		message = fmt.Sprintf("command %q exited with code %d", e.Command, e.ExitCode)
+	}
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		message += "\n" + stderr
+	}
+	return message
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// commandLine builds the argv for a terraform/tofu invocation. The configured command may carry its own arguments
+// (e.g. "tenv tf"). With a shell configured, the whole command line is passed to "<shell> -c" instead, quoted.
+func commandLine(settings ProjectSettings, args ...string) ([]string, error) {
+	argv := append(strings.Fields(settings.Command), args...)
+	if len(argv) == len(args) {
+		return nil, errors.New("no command configured")
+	}
+	if settings.Shell == "" {
+		return argv, nil
+	}
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = shellQuote(arg)
+	}
+	return []string{settings.Shell, "-c", strings.Join(quoted, " ")}, nil
+}
+
+// variableCommands are the terraform/tofu subcommands that evaluate input variables and so take -var-file.
+var variableCommands = map[string]bool{"apply": true, "console": true, "destroy": true, "import": true, "plan": true, "refresh": true}
+
+// withVarFiles adds a -var-file argument for each of the project's var files to a subcommand that evaluates input
+// variables. Relative var files are relative to the project directory.
+func withVarFiles(settings ProjectSettings, projectPath string, args []string) []string {
+	if len(args) == 0 || !variableCommands[args[0]] || len(settings.VarFiles) == 0 {
+		return args
+	}
+	withFiles := append([]string{}, args...)
+	for _, varFile := range settings.VarFiles {
+		withFiles = append(withFiles, "-var-file="+absolutePath(projectPath, varFile))
+	}
+	return withFiles
+}
+
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,@") == "" {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+func commandEnv(settings ProjectSettings) []string {
+	env := os.Environ()
+	for _, key := range sortedKeys(settings.Env) {
+		env = append(env, key+"="+settings.Env[key])
+	}
+	if settings.Workspace != "" {
+		env = append(env, "TF_WORKSPACE="+settings.Workspace)
+	}
+	return env
+}
+
+// runTerraform runs a terraform/tofu subcommand in projectPath and returns its stdout. Stderr is kept separate so that
+// warnings can't corrupt JSON output; it is logged in verbose mode and included in the error when the command fails.
+func runTerraform(ctx context.Context, settings ProjectSettings, projectPath string, verbose bool, args ...string) ([]byte, error) {
+	argv, err := commandLine(settings, withVarFiles(settings, projectPath, args)...)
+	if err != nil {
+		return nil, err
+	}
+	commandString := strings.Join(argv, " ")
+	if verbose {
+		log.Printf("Running command in %s: %s", projectPath, commandString)
+	}
+	if settings.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, settings.Timeout)
+		defer cancel()
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = projectPath
+	cmd.Env = commandEnv(settings)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Providers started by terraform can outlive it and hold the output pipes open after a timeout kill.
+	cmd.WaitDelay = 5 * time.Second
+	err = cmd.Run()
+	if verbose && stderr.Len() > 0 {
+		log.Printf("Stderr of %s:\n%s", commandString, stderr.String())
+	}
+	if err != nil {
+		commandErr := &CommandError{Command: commandString, ExitCode: -1, Stderr: stderr.String(), Err: err}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			commandErr.ExitCode = exitErr.ExitCode()
+			commandErr.Err = nil
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			commandErr.Err = fmt.Errorf("timed out after %s", settings.Timeout)
+		} else if ctx.Err() != nil {
+			commandErr.Err = ctx.Err()
+		}
+		return nil, commandErr
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandLine(t *testing.T) {
+	argv, err := commandLine(ProjectSettings{Command: "terraform"}, "show", "-json")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"terraform", "show", "-json"}, argv)
+
+	argv, err = commandLine(ProjectSettings{Command: "tenv tf"}, "show", "-json")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"tenv", "tf", "show", "-json"}, argv)
+
+	argv, err = commandLine(ProjectSettings{Command: "tofu", Shell: "bash"}, "plan", "-var-file=my vars.tfvars", "-var=x='y'")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bash", "-c", `tofu plan '-var-file=my vars.tfvars' '-var=x='\''y'\'''`}, argv)
+
+	_, err = commandLine(ProjectSettings{}, "show")
+	assert.NotNil(t, err)
+}
+
+func TestWithVarFiles(t *testing.T) {
+	settings := ProjectSettings{VarFiles: []string{"prod.tfvars", "/shared/common.tfvars"}}
+	assert.Equal(t, []string{"plan", "-input=false", "-var-file=/stacks/app/prod.tfvars", "-var-file=/shared/common.tfvars"},
+		withVarFiles(settings, "/stacks/app", []string{"plan", "-input=false"}))
+	assert.Equal(t, []string{"show", "-json"}, withVarFiles(settings, "/stacks/app", []string{"show", "-json"}))
+	assert.Equal(t, []string{"plan"}, withVarFiles(ProjectSettings{}, "/stacks/app", []string{"plan"}))
+}
+
+func TestRunTerraform(t *testing.T) {
+	settings := ProjectSettings{Command: "sh -c", Env: map[string]string{"TDI_TEST_VALUE": "from env"}}
+
+	output, err := runTerraform(context.Background(), settings, t.TempDir(), false, `echo "$TDI_TEST_VALUE"; echo warning >&2`)
+	assert.Nil(t, err)
+	assert.Equal(t, "from env\n", string(output))
+
+	_, err = runTerraform(context.Background(), settings, t.TempDir(), false, "echo locked >&2; exit 3")
+	var commandErr *CommandError
+	assert.True(t, errors.As(err, &commandErr))
+	assert.Equal(t, 3, commandErr.ExitCode)
+	assert.Equal(t, "locked\n", commandErr.Stderr)
+
+	settings.Timeout = 50 * time.Millisecond
+	_, err = runTerraform(context.Background(), settings, t.TempDir(), false, "exec sleep 5")
+	assert.ErrorContains(t, err, "timed out after 50ms")
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	stabilityStable       = "stable"
+	stabilityExperimental = "experimental"
+	stabilityDeprecated   = "deprecated"
+)
+
+var (
+	generatedOutputPattern    = regexp.MustCompile(`^output\s+"([^"]+)"`)
+	generatedStabilityPattern = regexp.MustCompile(`^# (Stable|Experimental|Deprecated)\b`)
+)
+
+// parseStabilityTag parses the arguments of @stable, @experimental or @deprecated("message") and returns the text
+// after them.
+func parseStabilityTag(tag string, rest string) (Annotation, string, error) {
+	annotation := Annotation{Stability: tag}
+	trimmed := strings.TrimLeft(rest, " \t")
+	if !strings.HasPrefix(trimmed, "(") {
+		return annotation, rest, nil
+	}
+	parser := argumentParser{tag: tag, input: trimmed, position: 1}
+	parser.skipSpaces()
+	if parser.consume(')') {
+		return annotation, parser.input[parser.position:], nil
+	}
+	if tag != stabilityDeprecated {
+		return annotation, "", fmt.Errorf("@%s takes no arguments", tag)
+	}
+	message, err := parser.stringLiteral()
+	if err != nil {
+		return annotation, "", err
+	}
+	parser.skipSpaces()
+	if !parser.consume(')') {
+		return annotation, "", parser.errorf("expected \")\" after the message")
+	}
+	annotation.Deprecation = message
+	return annotation, parser.input[parser.position:], nil
+}
+
+// stabilityDescription prefixes the description of an experimental or deprecated output so consumers see it in their
+// editor and in the registry docs.
+func stabilityDescription(annotation Annotation) string {
+	prefix := ""
+	switch annotation.Stability {
+	case stabilityExperimental:
+		prefix = "[EXPERIMENTAL]"
+	case stabilityDeprecated:
+		prefix = "[DEPRECATED]"
+		if annotation.Deprecation != "" {
+			prefix = "[DEPRECATED: " + annotation.Deprecation + "]"
+		}
+	}
+	if prefix == "" || annotation.Description == "" {
+		return prefix + annotation.Description
+	}
+	return prefix + " " + annotation.Description
+}
+
+// stabilityComment is the comment written above an output in the generated module. lintStability reads it back to
+// know what a previous run exported.
+func stabilityComment(annotation Annotation) string {
+	switch annotation.Stability {
+	case stabilityStable:
+		return "# Stable"
+	case stabilityExperimental:
+		return "# Experimental"
+	case stabilityDeprecated:
+		if annotation.Deprecation != "" {
+			return "# Deprecated: " + annotation.Deprecation
+		}
+		return "# Deprecated"
+	}
+	return ""
+}
+
+// checkVersionConstraint is written above the outputs of a module with check blocks, so that a consumer on a version
+// of Terraform without check blocks gets a version error rather than a parse error.
+const checkVersionConstraint = "terraform {\n  required_version = \">= 1.5\"\n}\n\n"
+
+// renderDeprecationCheck renders a check block that fails, and so shows a warning in every plan of a configuration
+// that uses the interface module, for as long as it exports the deprecated output. The warning is shown whether or not
+// the configuration reads that output.
+func renderDeprecationCheck(output AnnotatedOutput) string {
+	message := fmt.Sprintf("Output %s is deprecated", output.ExportedName())
+	if output.Annotation.Deprecation != "" {
+		message += ": " + output.Annotation.Deprecation
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "check \"deprecated_%s\" {\n", output.ExportedName())
+	fmt.Fprintln(&b, "  assert {")
+	fmt.Fprintln(&b, "    condition     = false")
+	fmt.Fprintf(&b, "    error_message = %s\n", hclString(message))
+	fmt.Fprintln(&b, "  }")
+	fmt.Fprintf(&b, "}\n\n")
+	return b.String()
+}
+
+// generatedStabilities reads the outputs of a generated outputs file with the stability they were generated with.
+func generatedStabilities(fs afero.Fs, path string) (map[string]string, error) {
+	stabilities := make(map[string]string)
+	content, _, err := fsReader(fs)(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, output := range parseGeneratedOutputs(content) {
+		stabilities[output.Name] = output.Stability
+	}
+	return stabilities, nil
+}
+
+// lintStability compares a plan with the interface modules on disk. A @stable output may only be removed from a
+// module after a run that generated it as @deprecated.
+func lintStability(fs afero.Fs, plan ProjectPlan) ([]string, error) {
+	// An audience that no longer has any outputs is missing from the plan, but its module is still on disk.
+	dirs, err := existingInterfaceDirs(fs, plan)
+	if err != nil {
+		return nil, err
+	}
+	exported := make(map[string]map[string]bool)
+	for _, module := range plan.Interfaces {
+		dirs = appendUnique(dirs, module.Dir)
+		exported[module.Dir] = make(map[string]bool)
+		for _, output := range module.Outputs {
+			exported[module.Dir][output.ExportedName()] = true
+		}
+	}
+	var problems []string
+	for _, dir := range dirs {
+		previous, err := generatedStabilities(fs, filepath.Join(dir, "generated_outputs.tf"))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range sortedKeys(previous) {
+			if previous[name] == stabilityStable && !exported[dir][name] {
+				problems = append(problems, fmt.Sprintf(
+					"stable output %s was removed from %s; mark it @deprecated and generate before removing it", name, dir))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// existingInterfaceDirs lists the public interface module of a plan and the audience modules that a previous run
+// generated next to it.
+func existingInterfaceDirs(fs afero.Fs, plan ProjectPlan) ([]string, error) {
+	dirs := []string{plan.InterfaceDir}
+	parent := filepath.Dir(plan.InterfaceDir)
+	if exists, _ := afero.DirExists(fs, parent); !exists {
+		return dirs, nil
+	}
+	infos, err := afero.ReadDir(fs, parent)
+	if err != nil {
+		return nil, err
+	}
+	var entries []string
+	for _, info := range infos {
+		if info.IsDir() {
+			entries = append(entries, info.Name())
+		}
+	}
+	for _, dir := range audienceInterfaceDirs(plan, entries) {
+		if exists, _ := afero.Exists(fs, filepath.Join(dir, "generated_outputs.tf")); exists {
+			dirs = appendUnique(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+// audienceInterfaceDirs picks the folders an audience module of a plan could have been generated in from the entries
+// of the directory that holds its public module.
+func audienceInterfaceDirs(plan ProjectPlan, entries []string) []string {
+	folderName := plan.Project.GeneratedFolderName
+	if folderName == "" {
+		folderName = "interface"
+	}
+	customFolders := make(map[string]bool)
+	for _, name := range plan.Project.AudienceFolders {
+		customFolders[name] = true
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry, folderName+"-") || customFolders[entry] {
+			dirs = append(dirs, filepath.Join(filepath.Dir(plan.InterfaceDir), entry))
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStabilityTags(t *testing.T) {
+	annotation, _, err := parseAnnotation(`# @public @deprecated("use \"subnet_ids\"")`)
+	assert.Nil(t, err)
+	assert.Equal(t, Annotation{Audiences: []string{"public"}, Stability: stabilityDeprecated, Deprecation: `use "subnet_ids"`}, annotation)
+
+	annotation, _, err = parseAnnotation(`# @stable @internal`)
+	assert.Nil(t, err)
+	assert.Equal(t, Annotation{Audiences: []string{"internal"}, Stability: stabilityStable}, annotation)
+
+	_, _, err = parseAnnotation(`# @stable("forever")`)
+	assert.ErrorContains(t, err, "@stable takes no arguments")
+	_, _, err = parseAnnotation(`# @stable @experimental`)
+	assert.ErrorContains(t, err, `conflicting values for stability: "stable" and "experimental"`)
+
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/project/main.tf", []byte("# @deprecated(\"gone soon\")\noutput \"old\" {\n  value = a.b.c\n}\n"), 0644)
+	_, err = projectOutputs(fs, "/project", false)
+	assert.EqualError(t, err, "main.tf:2: output is marked @deprecated but has no visibility tag such as @public")
+}
+
+func TestRenderDeprecatedOutput(t *testing.T) {
+	outputs := []AnnotatedOutput{
+		{Output: "subnet", Reference: "aws_subnet.main.id", Annotation: Annotation{Description: "The subnet", Stability: stabilityDeprecated, Deprecation: "use subnet_ids"}},
+		{Output: "subnet_ids", Reference: "aws_subnet.main.ids", Annotation: Annotation{Stability: stabilityExperimental}},
+	}
+	assert.Equal(t, `terraform {
+  required_version = ">= 1.5"
+}
+
+# Deprecated: use subnet_ids
+output "subnet" {
+  description = "[DEPRECATED: use subnet_ids] The subnet"
+  value = data.aws_subnet.main.id
+}
+
+check "deprecated_subnet" {
+  assert {
+    condition     = false
+    error_message = "Output subnet is deprecated: use subnet_ids"
+  }
+}
+
+# Experimental
+output "subnet_ids" {
+  description = "[EXPERIMENTAL]"
+  value = data.aws_subnet.main.ids
+}
+
+`, renderOutputsFile(outputs))
+	assert.NotContains(t, renderOutputsFile(outputs[1:]), "required_version")
+}
+
+func TestLintStability(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	state, err := os.ReadFile("testdata/fixtures/examples/simple/show.json")
+	assert.Nil(t, err)
+	schema, err := os.ReadFile("testdata/fixtures/examples/simple/providers_schema.json")
+	assert.Nil(t, err)
+	afero.WriteFile(fs, "/fixtures/show.json", state, 0644)
+	afero.WriteFile(fs, "/fixtures/providers_schema.json", schema, 0644)
+	runner := FixtureRunner{Fs: fs, Dir: "/fixtures", BaseDir: "/project"}
+	generate := func(source string) ProjectPlan {
+		afero.WriteFile(fs, "/project/main.tf", []byte(source), 0644)
+		plan, err := planProject(context.Background(), runner, fs, ProjectConfig{Path: "."}, "/project", ProjectSettings{Command: "terraform"}, false)
+		assert.Nil(t, err)
+		return plan
+	}
+
+	plan := generate("# @public @stable\noutput \"path\" {\n  value = local_file.my_local_file.filename\n}\n\n# @public\noutput \"id\" {\n  value = local_file.my_local_file.id\n}\n")
+	assert.Empty(t, plan.LintProblems)
+	assert.Nil(t, writePlan(fs, plan, false))
+
+	plan = generate("# @public\noutput \"id\" {\n  value = local_file.my_local_file.id\n}\n")
+	assert.Equal(t, []string{"stable output path was removed from /project/interface; mark it @deprecated and generate before removing it"}, plan.LintProblems)
+	plan = generate("")
+	assert.Len(t, plan.LintProblems, 1)
+
+	plan = generate("# @public @deprecated(\"use id\")\noutput \"path\" {\n  value = local_file.my_local_file.filename\n}\n")
+	assert.Empty(t, plan.LintProblems)
+	assert.Nil(t, writePlan(fs, plan, false))
+
+	plan = generate("# @public\noutput \"id\" {\n  value = local_file.my_local_file.id\n}\n")
+	assert.Empty(t, plan.LintProblems)
+	assert.Nil(t, writePlan(fs, plan, false))
+
+	plan = generate("# @internal @stable\noutput \"path\" {\n  value = local_file.my_local_file.filename\n}\n\n# @public\noutput \"id\" {\n  value = local_file.my_local_file.id\n}\n")
+	assert.Empty(t, plan.LintProblems)
+	assert.Nil(t, writePlan(fs, plan, false))
+	exists, _ := afero.Exists(fs, "/project/interface-internal/generated_outputs.tf")
+	assert.True(t, exists)
+
+	plan = generate("# @public\noutput \"id\" {\n  value = local_file.my_local_file.id\n}\n")
+	assert.Equal(t, []string{"stable output path was removed from /project/interface-internal; mark it @deprecated and generate before removing it"}, plan.LintProblems)
+}
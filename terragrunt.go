@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	terragruntFileName  = "terragrunt.hcl"
+	terragruntCacheName = ".terragrunt-cache"
+)
+
+var (
+	terragruntBlockPattern  = regexp.MustCompile(`^\s*(terraform|include(\s+"[^"]*")?)\s*\{`)
+	terragruntSourcePattern = regexp.MustCompile(`^\s*source\s*=\s*"([^"]*)"`)
+)
+
+// isTerragruntUnit reports whether a directory is a Terragrunt unit: it has a terragrunt.hcl with a terraform or
+// include block. A terragrunt.hcl with neither, such as a root config that units include, is not a unit.
+func isTerragruntUnit(fs afero.Fs, dir string) bool {
+	content, err := afero.ReadFile(fs, filepath.Join(dir, terragruntFileName))
+	if err != nil {
+		return false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if terragruntBlockPattern.MatchString(scanner.Text()) {
+			return true
+		}
+	}
+	return false
+}
+
+// terragruntSource returns the source set in the terraform block of a unit's terragrunt.hcl, or "" if there is none.
+func terragruntSource(content string) string {
+	depth := 0
+	inTerraform := false
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if depth == 0 && strings.HasPrefix(strings.TrimSpace(line), "terraform") && strings.Contains(line, "{") {
+			inTerraform = true
+		}
+		if inTerraform && depth == 1 {
+			if matches := terragruntSourcePattern.FindStringSubmatch(line); matches != nil {
+				return matches[1]
+			}
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth <= 0 {
+			depth = 0
+			inTerraform = false
+		}
+	}
+	return ""
+}
+
+// terragruntModuleDir finds the directory with the .tf files of a Terragrunt unit. A local source is resolved relative
+// to the unit, with "//" separating the module from its subdirectory. For a remote source, or a source set in an
+// included file, the module is looked up in the unit's .terragrunt-cache, which terragrunt init populates.
+func terragruntModuleDir(fs afero.Fs, unitDir string) (string, error) {
+	content, err := afero.ReadFile(fs, filepath.Join(unitDir, terragruntFileName))
+	if err != nil {
+		return "", err
+	}
+	source := terragruntSource(string(content))
+	source = strings.ReplaceAll(source, "${get_terragrunt_dir()}", unitDir)
+	if source == "" {
+		if files, _ := terraformFiles(fs, unitDir); len(files) > 0 {
+			return unitDir, nil
+		}
+	} else if isLocalSource(source) {
+		path := strings.Replace(source, "//", "/", 1)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(unitDir, path)
+		}
+		return filepath.Clean(path), nil
+	}
+	return terragruntCachedModule(fs, unitDir, source)
+}
+
+func isLocalSource(source string) bool {
+	if strings.Contains(source, "${") {
+		return false
+	}
+	return strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../") || filepath.IsAbs(source)
+}
+
+// terragruntCachedModule finds the working directory terragrunt created for a unit in its cache: the directory with
+// .tf files that terragrunt also copied the unit's terragrunt.hcl into. The most recently modified one wins.
+func terragruntCachedModule(fs afero.Fs, unitDir string, source string) (string, error) {
+	cacheDir := filepath.Join(unitDir, terragruntCacheName)
+	var found string
+	var foundTime int64
+	err := walkDirectories(fs, cacheDir, nil, func(dir string) error {
+		info, err := fs.Stat(filepath.Join(dir, terragruntFileName))
+		if err != nil {
+			return nil
+		}
+		files, err := terraformFiles(fs, dir)
+		if err != nil || len(files) == 0 {
+			return err
+		}
+		if found == "" || info.ModTime().UnixNano() > foundTime {
+			found, foundTime = dir, info.ModTime().UnixNano()
+		}
+		return nil
+	})
+	if found == "" {
+		if source == "" {
+			source = "(from an included file)"
+		}
+		return "", fmt.Errorf("no module found in %s for source %s, run terragrunt init in %s first: %v", cacheDir, source, unitDir, err)
+	}
+	return found, nil
+}
+
+// TerragruntRunner runs the commands of a Terragrunt unit with another runner and strips the log lines that
+// terragrunt can write to stdout around the JSON output.
+type TerragruntRunner struct {
+	Runner Runner
+}
+
+func (r TerragruntRunner) Run(ctx context.Context, settings ProjectSettings, projectPath string, args ...string) ([]byte, error) {
+	output, err := r.Runner.Run(ctx, settings, projectPath, args...)
+	if err != nil {
+		return nil, err
+	}
+	return stripTerragruntLogs(output), nil
+}
+
+// stripTerragruntLogs returns the first JSON document with a format_version in output, which is what both show -json
+// and providers schema -json print. Output without one is returned as it is.
+func stripTerragruntLogs(output []byte) []byte {
+	offset := 0
+	for _, line := range bytes.SplitAfter(output, []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimSpace(line), []byte("{")) {
+			rest := output[offset:]
+			decoder := json.NewDecoder(bytes.NewReader(rest))
+			var document map[string]json.RawMessage
+			if err := decoder.Decode(&document); err == nil {
+				if _, ok := document["format_version"]; ok {
+					return bytes.TrimSpace(rest[:decoder.InputOffset()])
+				}
+			}
+		}
+		offset += len(line)
+	}
+	return output
+}
+
+// terragruntSettings runs a unit with the terragrunt command, unless the configured command already is terragrunt,
+// and keeps terragrunt from prompting.
+func terragruntSettings(settings ProjectSettings) ProjectSettings {
+	if fields := strings.Fields(settings.Command); len(fields) == 0 || filepath.Base(fields[0]) != "terragrunt" {
+		settings.Command = settings.Terragrunt
+	}
+	env := map[string]string{"TERRAGRUNT_NON_INTERACTIVE": "true", "TG_NON_INTERACTIVE": "true"}
+	for key, value := range settings.Env {
+		env[key] = value
+	}
+	settings.Env = env
+	return settings
+}
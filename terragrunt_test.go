@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTerragruntModuleDir(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afero.WriteFile(fs, "/live/vpc/terragrunt.hcl", []byte(`include "root" {
+  path = find_in_parent_folders()
+}
+
+terraform {
+  extra_arguments "retry" {
+    commands = ["plan"]
+  }
+  source = "../../modules//vpc"
+}
+`), 0644)
+	afero.WriteFile(fs, "/live/dns/terragrunt.hcl", []byte("terraform {\n  source = \"${get_terragrunt_dir()}/../../modules/dns\"\n}\n"), 0644)
+	afero.WriteFile(fs, "/live/local/terragrunt.hcl", []byte("terraform {}\n"), 0644)
+	afero.WriteFile(fs, "/live/local/main.tf", []byte("output \"x\" {}\n"), 0644)
+	afero.WriteFile(fs, "/live/db/terragrunt.hcl", []byte("terraform {\n  source = \"git::https://example.com/modules.git//db?ref=v1.2.0\"\n}\n"), 0644)
+	afero.WriteFile(fs, "/live/db/.terragrunt-cache/abc/def/db/terragrunt.hcl", []byte(""), 0644)
+	afero.WriteFile(fs, "/live/db/.terragrunt-cache/abc/def/db/main.tf", []byte(""), 0644)
+	afero.WriteFile(fs, "/live/db/.terragrunt-cache/abc/def/modules/other.tf", []byte(""), 0644)
+	afero.WriteFile(fs, "/live/cache/terragrunt.hcl", []byte("terraform {\n  source = \"tfr:///terraform-aws-modules/vpc/aws?version=5.0.0\"\n}\n"), 0644)
+	afero.WriteFile(fs, "/live/terragrunt.hcl", []byte("remote_state {\n  backend = \"s3\"\n}\n"), 0644)
+
+	for unit, expected := range map[string]string{
+		"/live/vpc":   "/modules/vpc",
+		"/live/dns":   "/modules/dns",
+		"/live/local": "/live/local",
+		"/live/db":    "/live/db/.terragrunt-cache/abc/def/db",
+	} {
+		assert.True(t, isTerragruntUnit(fs, unit), unit)
+		moduleDir, err := terragruntModuleDir(fs, unit)
+		assert.Nil(t, err, unit)
+		assert.Equal(t, expected, moduleDir, unit)
+	}
+
+	_, err := terragruntModuleDir(fs, "/live/cache")
+	assert.ErrorContains(t, err, "run terragrunt init")
+	assert.False(t, isTerragruntUnit(fs, "/live"))
+
+	roots, err := discoverTerraformRoots(fs, "/live")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/live/cache", "/live/db", "/live/dns", "/live/local", "/live/vpc"}, roots)
+}
+
+func TestStripTerragruntLogs(t *testing.T) {
+	output := "time=2026-10-18T11:32:04Z level=info msg=Downloading Terraform configurations\n" +
+		"{\"level\":\"info\",\"msg\":\"json log line\"}\n" +
+		"{\"format_version\":\"1.0\",\"values\":{}}\n" +
+		"INFO[0003] done\n"
+	assert.Equal(t, `{"format_version":"1.0","values":{}}`, string(stripTerragruntLogs([]byte(output))))
+	assert.Equal(t, "not json", string(stripTerragruntLogs([]byte("not json"))))
+}
+
+func TestTerragruntSettings(t *testing.T) {
+	settings := terragruntSettings(ProjectSettings{Command: "tofu", Terragrunt: "terragrunt", Env: map[string]string{"TG_NON_INTERACTIVE": "false"}})
+	assert.Equal(t, "terragrunt", settings.Command)
+	assert.Equal(t, "false", settings.Env["TG_NON_INTERACTIVE"])
+	assert.Equal(t, "true", settings.Env["TERRAGRUNT_NON_INTERACTIVE"])
+
+	settings = terragruntSettings(ProjectSettings{Command: "/usr/local/bin/terragrunt --no-color", Terragrunt: "terragrunt"})
+	assert.Equal(t, "/usr/local/bin/terragrunt --no-color", settings.Command)
+}
+
+func TestPlanTerragruntUnit(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	mainTf, err := os.ReadFile("examples/simple/main.tf")
+	assert.Nil(t, err)
+	state, err := os.ReadFile("testdata/fixtures/examples/simple/show.json")
+	assert.Nil(t, err)
+	schema, err := os.ReadFile("testdata/fixtures/examples/simple/providers_schema.json")
+	assert.Nil(t, err)
+	afero.WriteFile(fs, "/repo/modules/simple/main.tf", mainTf, 0644)
+	afero.WriteFile(fs, "/repo/live/simple/terragrunt.hcl", []byte("terraform {\n  source = \"../../modules/simple\"\n}\n"), 0644)
+	afero.WriteFile(fs, "/fixtures/live/simple/show.json", append([]byte("12:00:01.000 INFO   Downloading Terraform configurations\n"), state...), 0644)
+	afero.WriteFile(fs, "/fixtures/live/simple/providers_schema.json", schema, 0644)
+	runner := FixtureRunner{Fs: fs, Dir: "/fixtures", BaseDir: "/repo"}
+
+	plan, err := planProject(context.Background(), runner, fs, ProjectConfig{Path: "live/simple"}, "/repo",
+		ProjectSettings{Command: "terraform", Terragrunt: "terragrunt", Timeout: time.Minute}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "terragrunt", plan.Settings.Command)
+	assert.Len(t, plan.Outputs, 6)
+	assert.Equal(t, "../../modules/simple/main.tf", plan.Outputs[0].File)
+	assert.Len(t, plan.DataSources, 1)
+	assert.Equal(t, "/repo/live/simple/interface", plan.InterfaceDir)
+}
@@ -0,0 +1,4 @@
+data "local_file" "my_local_file" {
+  filename = "./random_string.txt"
+}
+
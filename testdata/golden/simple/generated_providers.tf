@@ -0,0 +1,7 @@
+terraform {
+  required_providers {
+    local = {
+      source = "registry.terraform.io/hashicorp/local"
+    }
+  }
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The policies for a lookup value that is not in the producer's state, set per project with unresolved.
+const (
+	unresolvedFail     = "fail"
+	unresolvedSkip     = "skip"
+	unresolvedVariable = "variable"
+)
+
+func validUnresolvedPolicy(policy string) bool {
+	return policy == "" || policy == unresolvedFail || policy == unresolvedSkip || policy == unresolvedVariable
+}
+
+// unresolvedAttributes are the lookup attributes of a data source that were not found in state.
+func unresolvedAttributes(dataSource DataSourcePlan) []string {
+	var attributes []string
+	for _, lookup := range dataSource.Lookups {
+		if !lookup.Found {
+			attributes = append(attributes, lookup.Attribute)
+		}
+	}
+	return attributes
+}
+
+// resolveLookups applies a project's unresolved policy to the data sources with lookup values that were not found in
+// state. fail, the default, returns an error. skip drops the data sources and skips the outputs that read them.
+// variable keeps them, and renderDataFile reads the missing values from required variables instead.
+func resolveLookups(policy string, dataSources []DataSourcePlan, outputs []AnnotatedOutput) ([]DataSourcePlan, []SkippedOutput, error) {
+	if policy == unresolvedVariable {
+		return dataSources, nil, nil
+	}
+	var resolved []DataSourcePlan
+	var skipped []SkippedOutput
+	for _, dataSource := range dataSources {
+		attributes := unresolvedAttributes(dataSource)
+		if len(attributes) == 0 {
+			resolved = append(resolved, dataSource)
+			continue
+		}
+		reason := fmt.Sprintf("lookup value %s of data source %s.%s not found in state", strings.Join(attributes, ", "), dataSource.Type, dataSource.Name)
+		if policy != unresolvedSkip {
+			return nil, nil, fmt.Errorf("%s, set unresolved to skip or variable on the project to generate the interface anyway", reason)
+		}
+		for _, output := range outputs {
+			if strings.HasPrefix(output.Reference, dataSource.Type+"."+dataSource.Name+".") {
+				skipped = append(skipped, SkippedOutput{Output: output, Reason: reason})
+			}
+		}
+	}
+	return resolved, skipped, nil
+}
+
+// lookupVariable is the name of the variable that an unresolved lookup value is read from.
+func lookupVariable(dataSource DataSourcePlan, lookup LookupValue) string {
+	return fmt.Sprintf("%s_%s_%s", dataSource.Type, dataSource.Name, lookup.Attribute)
+}
+
+func lookupVariables(dataSources []DataSourcePlan) []string {
+	var variables []string
+	for _, dataSource := range dataSources {
+		for _, lookup := range dataSource.Lookups {
+			if !lookup.Found {
+				variables = appendUnique(variables, lookupVariable(dataSource, lookup))
+			}
+		}
+	}
+	return variables
+}
+
+func renderVariablesFile(variables []string) string {
+	var b strings.Builder
+	for _, variable := range variables {
+		fmt.Fprintf(&b, "variable \"%s\" {\n", variable)
+		fmt.Fprintln(&b, "  type        = string")
+		fmt.Fprintln(&b, "  description = \"Lookup value that was not found in the producer's state.\"")
+		fmt.Fprintf(&b, "}\n\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveLookups(t *testing.T) {
+	dataSources := []DataSourcePlan{
+		{Type: "aws_vpc", Name: "main", Lookups: []LookupValue{{Attribute: "id", Value: "vpc-123", Found: true}}},
+		{Type: "aws_subnet", Name: "main", Lookups: []LookupValue{{Attribute: "id"}}},
+	}
+	outputs := []AnnotatedOutput{
+		{Output: "vpc_id", Reference: "aws_vpc.main.id"},
+		{Output: "subnet_id", Reference: "aws_subnet.main.id"},
+		{Output: "subnet_arn", Reference: "aws_subnet.main.arn"},
+	}
+
+	_, _, err := resolveLookups("", dataSources, outputs)
+	assert.EqualError(t, err, "lookup value id of data source aws_subnet.main not found in state, set unresolved to skip or variable on the project to generate the interface anyway")
+
+	resolved, skipped, err := resolveLookups(unresolvedSkip, dataSources, outputs)
+	assert.Nil(t, err)
+	assert.Equal(t, dataSources[:1], resolved)
+	assert.Equal(t, []SkippedOutput{
+		{Output: outputs[1], Reason: "lookup value id of data source aws_subnet.main not found in state"},
+		{Output: outputs[2], Reason: "lookup value id of data source aws_subnet.main not found in state"},
+	}, skipped)
+
+	resolved, skipped, err = resolveLookups(unresolvedVariable, dataSources, outputs)
+	assert.Nil(t, err)
+	assert.Equal(t, dataSources, resolved)
+	assert.Empty(t, skipped)
+}
+
+func TestRenderInterfaceUnresolvedVariables(t *testing.T) {
+	dataSources := []DataSourcePlan{
+		{Type: "aws_vpc", Name: "main", Lookups: []LookupValue{{Attribute: "id", Value: "vpc-123", Found: true}}},
+		{Type: "aws_subnet", Name: "main", Lookups: []LookupValue{{Attribute: "id"}}},
+	}
+	module := InterfacePlan{Dir: "/interface", Groups: []string{"network"}, Outputs: []AnnotatedOutput{
+		{Output: "vpc_id", Reference: "aws_vpc.main.id"},
+		{Output: "subnet_id", Reference: "aws_subnet.main.id", Annotation: Annotation{Group: "network"}},
+	}}
+
+	files := make(map[string]string)
+	for _, file := range renderInterface(module, dataSources, nil, ProviderSchema{}) {
+		files[file.Path] = file.Content
+	}
+	assert.Equal(t, "data \"aws_subnet\" \"main\" {\n  id = var.aws_subnet_main_id\n}\n\n", files["/interface/modules/network/generated_data.tf"])
+	assert.NotContains(t, files["/interface/generated_data.tf"], `""`)
+	assert.Equal(t, "module \"network\" {\n  source = \"./modules/network\"\n  aws_subnet_main_id = var.aws_subnet_main_id\n}\n\n", files["/interface/generated_modules.tf"])
+	assert.Equal(t, renderVariablesFile([]string{"aws_subnet_main_id"}), files["/interface/generated_variables.tf"])
+	assert.Equal(t, renderVariablesFile([]string{"aws_subnet_main_id"}), files["/interface/modules/network/generated_variables.tf"])
+	assert.Equal(t, []VariableSnapshot{{Name: "aws_subnet_main_id", Required: true}}, parseGeneratedVariables(files["/interface/generated_variables.tf"]))
+}
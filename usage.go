@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	moduleBlockPattern     = regexp.MustCompile(`^\s*module\s+"([^"]+)"\s*\{`)
+	moduleSourcePattern    = regexp.MustCompile(`^\s*source\s*=\s*"([^"]+)"`)
+	moduleReferencePattern = regexp.MustCompile(`\bmodule\.([A-Za-z_][A-Za-z0-9_-]*)\.([A-Za-z_][A-Za-z0-9_-]*)`)
+)
+
+// UsageReport is which consumers use which outputs of each interface module.
+type UsageReport struct {
+	Interfaces []InterfaceUsage `json:"interfaces"`
+}
+
+type InterfaceUsage struct {
+	Dir      string        `json:"dir"`
+	Project  string        `json:"project"`
+	Audience string        `json:"audience"`
+	Outputs  []OutputUsage `json:"outputs"`
+	// Missing are references to outputs that the interface module does not export (anymore).
+	Missing []UsageLocation `json:"missing"`
+}
+
+type OutputUsage struct {
+	Name      string          `json:"name"`
+	Consumers []UsageLocation `json:"consumers"`
+}
+
+// UsageLocation is a reference to an interface output in a consumer, such as module.network.vpc_id.
+type UsageLocation struct {
+	Reference string `json:"reference"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+}
+
+// moduleCall is a module block in a consumer that sources an interface module or one of its group submodules.
+type moduleCall struct {
+	entry int
+	group string
+}
+
+// scanUsage finds the module blocks below the consumer roots that source one of the interface modules in the catalog,
+// and the references to their outputs. Module names are scoped to a directory, like Terraform does. File paths in
+// the report are relative to currentDir.
+func scanUsage(fs afero.Fs, currentDir string, catalog Catalog, consumers []string) (UsageReport, error) {
+	report := UsageReport{Interfaces: []InterfaceUsage{}}
+	used := make([]map[string][]UsageLocation, len(catalog.Interfaces))
+	for i, entry := range catalog.Interfaces {
+		report.Interfaces = append(report.Interfaces, InterfaceUsage{Dir: entry.Dir, Project: entry.Project, Audience: entry.Audience, Outputs: []OutputUsage{}, Missing: []UsageLocation{}})
+		used[i] = make(map[string][]UsageLocation)
+	}
+	for _, consumer := range consumers {
+		err := walkDirectories(fs, consumer, nil, func(dir string) error {
+			files, err := afero.Glob(fs, filepath.Join(dir, "*.tf"))
+			if err != nil {
+				return err
+			}
+			calls := make(map[string]moduleCall)
+			for _, file := range files {
+				if err := scanModuleCalls(fs, file, dir, currentDir, catalog, calls); err != nil {
+					return err
+				}
+			}
+			if len(calls) == 0 {
+				return nil
+			}
+			for _, file := range files {
+				if err := scanModuleReferences(fs, file, relativeTo(currentDir, file), catalog, calls, used, &report); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return report, fmt.Errorf("failed to scan %s: %v", consumer, err)
+		}
+	}
+	for i, entry := range catalog.Interfaces {
+		for _, output := range entry.Outputs {
+			locations := used[i][output.Name]
+			if locations == nil {
+				locations = []UsageLocation{}
+			}
+			report.Interfaces[i].Outputs = append(report.Interfaces[i].Outputs, OutputUsage{Name: output.Name, Consumers: locations})
+		}
+	}
+	return report, nil
+}
+
+func scanModuleCalls(fs afero.Fs, file string, dir string, currentDir string, catalog Catalog, calls map[string]moduleCall) error {
+	content, err := afero.ReadFile(fs, file)
+	if err != nil {
+		return err
+	}
+	name := ""
+	depth := 0
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name == "" {
+			if matches := moduleBlockPattern.FindStringSubmatch(line); matches != nil {
+				name = matches[1]
+				depth = 0
+			}
+		}
+		if name == "" {
+			continue
+		}
+		if matches := moduleSourcePattern.FindStringSubmatch(line); matches != nil && depth == 1 {
+			if entry, group, found := matchInterface(matches[1], dir, currentDir, catalog); found {
+				calls[name] = moduleCall{entry: entry, group: group}
+			}
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth <= 0 {
+			name = ""
+		}
+	}
+	return nil
+}
+
+func scanModuleReferences(fs afero.Fs, file string, displayPath string, catalog Catalog, calls map[string]moduleCall, used []map[string][]UsageLocation, report *UsageReport) error {
+	content, err := afero.ReadFile(fs, file)
+	if err != nil {
+		return err
+	}
+	lineNumber := 0
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		for _, matches := range moduleReferencePattern.FindAllStringSubmatch(line, -1) {
+			call, exists := calls[matches[1]]
+			if !exists {
+				continue
+			}
+			location := UsageLocation{Reference: matches[0], File: displayPath, Line: lineNumber}
+			if exportsOutput(catalog.Interfaces[call.entry], call.group, matches[2]) {
+				used[call.entry][matches[2]] = append(used[call.entry][matches[2]], location)
+			} else {
+				report.Interfaces[call.entry].Missing = append(report.Interfaces[call.entry].Missing, location)
+			}
+		}
+	}
+	return nil
+}
+
+// matchInterface finds the interface module a module source points to: a local path to it, or a remote address
+// whose subdirectory after "//" is its path, such as git::https://example.com/infra.git//stacks/network/interface.
+func matchInterface(source string, dir string, currentDir string, catalog Catalog) (int, string, bool) {
+	var target string
+	if isLocalSource(source) {
+		target = relativeTo(currentDir, filepath.Join(dir, source))
+	} else {
+		address := strings.SplitN(source, "?", 2)[0]
+		if index := strings.Index(address, "://"); index >= 0 {
+			address = address[index+3:]
+		}
+		index := strings.Index(address, "//")
+		if index < 0 {
+			return 0, "", false
+		}
+		target = address[index+2:]
+	}
+	target = path.Clean(filepath.ToSlash(target))
+	for i, entry := range catalog.Interfaces {
+		interfaceDir := filepath.ToSlash(entry.Dir)
+		if target == interfaceDir {
+			return i, "", true
+		}
+		if group := strings.TrimPrefix(target, interfaceDir+"/modules/"); group != target && !strings.Contains(group, "/") {
+			return i, group, true
+		}
+	}
+	return 0, "", false
+}
+
+// exportsOutput reports whether an interface module, or its submodule for group, exports an output.
+func exportsOutput(entry CatalogEntry, group string, name string) bool {
+	for _, output := range entry.Outputs {
+		if output.Name == name && (group == "" || output.Group == group) {
+			return true
+		}
+	}
+	return false
+}
+
+func printUsageReport(w io.Writer, report UsageReport) {
+	for _, module := range report.Interfaces {
+		fmt.Fprintf(w, "\033[1;33mInterface: %s (%s, %s)\033[0m\n", module.Dir, module.Project, module.Audience)
+		for _, output := range module.Outputs {
+			if len(output.Consumers) == 0 {
+				fmt.Fprintf(w, "  %s: no consumers\n", output.Name)
+				continue
+			}
+			fmt.Fprintf(w, "  %s:\n", output.Name)
+			for _, location := range output.Consumers {
+				fmt.Fprintf(w, "\033[32m    %s:%d\033[0m\n", location.File, location.Line)
+			}
+		}
+		for _, location := range module.Missing {
+			fmt.Fprintf(w, "\033[31m  %s:%d: %s is not exported by %s\033[0m\n", location.File, location.Line, location.Reference, module.Dir)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanUsage(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	catalog := Catalog{Interfaces: []CatalogEntry{
+		{Dir: "stacks/network/interface", Manifest: Manifest{Project: "stacks/network", Audience: audiencePublic, Outputs: []ManifestOutput{
+			{Name: "vpc_id"}, {Name: "zone_id", Group: "dns"}, {Name: "unused"},
+		}}},
+	}}
+	afero.WriteFile(fs, "/repo/apps/web/main.tf", []byte(`module "network" {
+  source = "../../stacks/network/interface"
+}
+
+module "dns" {
+  source = "git::https://example.com/infra.git//stacks/network/interface/modules/dns?ref=v1.2.0"
+}
+
+module "other" {
+  source = "../../modules/other"
+}
+
+resource "aws_instance" "web" {
+  # module.network.commented_out
+  subnet_id = module.network.vpc_id
+  zone      = module.dns.zone_id
+  vpc       = module.dns.vpc_id
+  other     = module.other.vpc_id
+}
+`), 0644)
+	afero.WriteFile(fs, "/repo/apps/web/outputs.tf", []byte("output \"vpc\" {\n  value = \"${module.network.vpc_id}-${module.network.subnet_id}\"\n}\n"), 0644)
+	afero.WriteFile(fs, "/repo/apps/api/main.tf", []byte("output \"vpc\" {\n  value = module.network.vpc_id\n}\n"), 0644)
+
+	report, err := scanUsage(fs, "/repo", catalog, []string{"/repo/apps"})
+	assert.Nil(t, err)
+	assert.Equal(t, []OutputUsage{
+		{Name: "vpc_id", Consumers: []UsageLocation{
+			{Reference: "module.network.vpc_id", File: "apps/web/main.tf", Line: 15},
+			{Reference: "module.network.vpc_id", File: "apps/web/outputs.tf", Line: 2},
+		}},
+		{Name: "zone_id", Consumers: []UsageLocation{{Reference: "module.dns.zone_id", File: "apps/web/main.tf", Line: 16}}},
+		{Name: "unused", Consumers: []UsageLocation{}},
+	}, report.Interfaces[0].Outputs)
+	assert.Equal(t, []UsageLocation{
+		{Reference: "module.dns.vpc_id", File: "apps/web/main.tf", Line: 17},
+		{Reference: "module.network.subnet_id", File: "apps/web/outputs.tf", Line: 2},
+	}, report.Interfaces[0].Missing)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// verifyPlan verifies each interface module of a plan with verifyInterface.
+func verifyPlan(ctx context.Context, runner Runner, settings ProjectSettings, plan ProjectPlan, withPlan bool) ([]string, error) {
+	var problems []string
+	for _, module := range plan.Interfaces {
+		var files []GeneratedFile
+		for _, file := range plan.Files {
+			if strings.HasPrefix(file.Path, module.Dir+string(filepath.Separator)) {
+				files = append(files, file)
+			}
+		}
+		variables := lookupVariables(moduleDataSources(module.Outputs, plan.DataSources))
+		moduleProblems, err := verifyInterface(ctx, runner, settings, plan.FullPath, module, files, variables, plan.StateOutputs, withPlan)
+		if err != nil {
+			return problems, fmt.Errorf("failed to verify %s: %v", module.Dir, err)
+		}
+		for _, problem := range moduleProblems {
+			problems = append(problems, fmt.Sprintf("%s: %s", module.Dir, problem))
+		}
+	}
+	return problems, nil
+}
+
+// plannedModule is a module in the prior state of a plan, which holds the data sources the plan read.
+type plannedModule struct {
+	Resources []struct {
+		Mode            string                 `json:"mode"`
+		Type            string                 `json:"type"`
+		Name            string                 `json:"name"`
+		Values          map[string]interface{} `json:"values"`
+		SensitiveValues map[string]interface{} `json:"sensitive_values"`
+	} `json:"resources"`
+	ChildModules []plannedModule `json:"child_modules"`
+}
+
+// dataSourceValue finds the value a plan read for an attribute of a data source, such as data.aws_vpc.main.id, in a
+// module or in one of its group submodules.
+func (module plannedModule) dataSourceValue(reference string) (interface{}, bool, bool) {
+	parts := strings.Split(reference, ".")
+	for _, resource := range module.Resources {
+		if resource.Mode != "data" || resource.Type != parts[1] || resource.Name != parts[2] {
+			continue
+		}
+		if value, found := resource.Values[parts[3]]; found {
+			return value, resource.SensitiveValues[parts[3]] == true, true
+		}
+	}
+	for _, child := range module.ChildModules {
+		if value, sensitive, found := child.dataSourceValue(reference); found {
+			return value, sensitive, true
+		}
+	}
+	return nil, false, false
+}
+
+// verifyInterface writes an interface module to a temporary directory and runs init -backend=false and validate in
+// it. With withPlan it also plans the module against the real providers, which reads every data source, and compares
+// the value each output reads, from its data source or from the remote state, with the producer's output in state.
+// variables are the required variables of the module, which plan needs a value for.
+func verifyInterface(ctx context.Context, runner Runner, settings ProjectSettings, projectPath string, module InterfacePlan, files []GeneratedFile, variables []string, stateOutputs map[string]StateOutput, withPlan bool) ([]string, error) {
+	dir, err := os.MkdirTemp("", "tf-interfaces-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	for _, file := range files {
+		path := filepath.Join(dir, relativeTo(module.Dir, file.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(file.Content), 0644); err != nil {
+			return nil, err
+		}
+	}
+	// The producer's workspace does not exist in the copy, which has no backend, and its var files are relative to
+	// the producer.
+	settings.Workspace = ""
+	varFiles := settings.VarFiles
+	settings.VarFiles = nil
+	for _, varFile := range varFiles {
+		settings.VarFiles = append(settings.VarFiles, absolutePath(projectPath, varFile))
+	}
+	if _, err := runner.Run(ctx, settings, dir, "init", "-backend=false", "-input=false", "-no-color"); err != nil {
+		return []string{fmt.Sprintf("init failed: %v", err)}, nil
+	}
+	if _, err := runner.Run(ctx, settings, dir, "validate", "-no-color"); err != nil {
+		return []string{fmt.Sprintf("validate failed: %v", err)}, nil
+	}
+	if !withPlan {
+		return nil, nil
+	}
+	if unset := unsetVariables(settings, variables); len(unset) > 0 {
+		return []string{fmt.Sprintf("plan skipped: lookup values that are not in the producer's state are read from the required variables %s, set them with TF_VAR_<name> to plan", strings.Join(unset, ", "))}, nil
+	}
+	if _, err := runner.Run(ctx, settings, dir, "plan", "-input=false", "-lock=false", "-no-color", "-out=tfplan"); err != nil {
+		return []string{fmt.Sprintf("plan failed, so a data source could not be read: %v", err)}, nil
+	}
+	output, err := runner.Run(ctx, settings, dir, "show", "-json", "tfplan")
+	if err != nil {
+		return nil, err
+	}
+	var planned struct {
+		PlannedValues struct {
+			Outputs map[string]map[string]json.RawMessage `json:"outputs"`
+		} `json:"planned_values"`
+		PriorState struct {
+			Values struct {
+				RootModule plannedModule `json:"root_module"`
+			} `json:"values"`
+		} `json:"prior_state"`
+	}
+	if err := json.Unmarshal(output, &planned); err != nil {
+		return nil, fmt.Errorf("failed to parse the plan: %v", err)
+	}
+	var problems []string
+	for _, annotated := range module.Outputs {
+		name := annotated.ExportedName()
+		plannedOutput, exists := planned.PlannedValues.Outputs[name]
+		if !exists || plannedOutput["value"] == nil {
+			problems = append(problems, fmt.Sprintf("output %s is unknown after plan", name))
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(plannedOutput["value"], &value); err != nil {
+			return nil, fmt.Errorf("failed to parse the value of output %s: %v", name, err)
+		}
+		sensitive := string(plannedOutput["sensitive"]) == "true"
+		source := "output " + name
+		if annotated.Annotation.Strategy != strategyRemoteState {
+			reference := outputValue(annotated)
+			if dataSourceValue, dataSourceSensitive, found := planned.PriorState.Values.RootModule.dataSourceValue(reference); found {
+				source, value, sensitive = reference, dataSourceValue, sensitive || dataSourceSensitive
+			}
+		}
+		if problem := valueMismatch(source, annotated, value, stateOutputs, sensitive); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	return problems, nil
+}
+
+// unsetVariables are the variables that neither the environment nor the settings give a value with TF_VAR_<name>.
+func unsetVariables(settings ProjectSettings, variables []string) []string {
+	var unset []string
+	for _, variable := range variables {
+		if _, set := settings.Env["TF_VAR_"+variable]; set {
+			continue
+		}
+		if _, set := os.LookupEnv("TF_VAR_" + variable); !set {
+			unset = append(unset, variable)
+		}
+	}
+	return unset
+}
+
+// valueMismatch describes how the value that source, such as the attribute of a data source, has for an annotated
+// output differs from the producer's output in state, or returns "" if they are equal. Sensitive values are redacted.
+func valueMismatch(source string, output AnnotatedOutput, value interface{}, stateOutputs map[string]StateOutput, sensitive bool) string {
+	stateOutput, exists := stateOutputs[output.Output]
+	if !exists {
+		return fmt.Sprintf("output %s is not in the producer's state", output.Output)
+	}
+	if reflect.DeepEqual(value, stateOutput.Value) {
+		return ""
+	}
+	if sensitive || stateOutput.Sensitive {
+		return fmt.Sprintf("%s differs from output %s in the producer's state (sensitive values redacted)", source, output.Output)
+	}
+	return fmt.Sprintf("%s is %s, but output %s in the producer's state is %s", source, jsonValue(value), output.Output, jsonValue(stateOutput.Value))
+}
+
+func jsonValue(value interface{}) string {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(content)
+}
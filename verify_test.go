@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptedRunner returns canned output for each command and records what it ran, with the var files ExecRunner adds.
+type scriptedRunner struct {
+	outputs map[string]string
+	failing map[string]bool
+	ran     *[]string
+}
+
+func (r scriptedRunner) Run(ctx context.Context, settings ProjectSettings, projectPath string, args ...string) ([]byte, error) {
+	*r.ran = append(*r.ran, strings.Join(withVarFiles(settings, projectPath, args), " "))
+	if args[0] == "init" {
+		if _, err := os.Stat(filepath.Join(projectPath, "modules", "files", "generated_outputs.tf")); err != nil {
+			return nil, err
+		}
+	}
+	if r.failing[args[0]] {
+		return nil, errors.New("Error: Invalid reference")
+	}
+	return []byte(r.outputs[args[0]]), nil
+}
+
+func TestVerifyInterface(t *testing.T) {
+	module := InterfacePlan{Dir: "/producer/interface", Outputs: []AnnotatedOutput{
+		{Output: "vpc_id", Reference: "aws_vpc.main.id"},
+		{Output: "password", Reference: "random_password.main.result"},
+		{Output: "zone_id", Reference: "aws_route53_zone.main.zone_id", Annotation: Annotation{Name: "zone"}},
+		{Output: "subnet_ids", Reference: "aws_subnet.main.id"},
+		{Output: "cidr", Annotation: Annotation{Strategy: strategyRemoteState}},
+	}}
+	files := []GeneratedFile{
+		{Path: "/producer/interface/generated_outputs.tf", Content: "output \"vpc_id\" {}\n"},
+		{Path: "/producer/interface/modules/files/generated_outputs.tf", Content: ""},
+	}
+	stateOutputs := map[string]StateOutput{
+		"vpc_id":     {Value: "vpc-123"},
+		"password":   {Value: "secret", Sensitive: true},
+		"zone_id":    {Value: "Z123"},
+		"subnet_ids": {Value: []interface{}{"subnet-1"}},
+		"cidr":       {Value: "10.0.0.0/8"},
+	}
+	var ran []string
+	runner := scriptedRunner{ran: &ran, outputs: map[string]string{"show": `{"planned_values": {"outputs": {
+		"vpc_id": {"sensitive": false, "value": "arn:aws:ec2:eu-west-1:123456789012:vpc/vpc-123"},
+		"password": {"sensitive": true, "value": "other"},
+		"zone": {"sensitive": false},
+		"subnet_ids": {"sensitive": false, "value": ["subnet-1"]},
+		"cidr": {"sensitive": false, "value": "10.0.0.0/16"}
+	}}, "prior_state": {"values": {"root_module": {
+		"resources": [
+			{"mode": "data", "type": "aws_vpc", "name": "main", "values": {"id": "arn:aws:ec2:eu-west-1:123456789012:vpc/vpc-123"}},
+			{"mode": "data", "type": "random_password", "name": "main", "values": {"result": "other"}, "sensitive_values": {"result": true}}
+		],
+		"child_modules": [{"resources": [
+			{"mode": "data", "type": "aws_subnet", "name": "main", "values": {"id": "subnet-2"}}
+		]}]
+	}}}}`}}
+	settings := ProjectSettings{Command: "terraform", Workspace: "prod", VarFiles: []string{"prod.tfvars"}}
+
+	problems, err := verifyInterface(context.Background(), runner, settings, "/producer", module, files, nil, stateOutputs, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"init -backend=false -input=false -no-color",
+		"validate -no-color",
+		"plan -input=false -lock=false -no-color -out=tfplan -var-file=/producer/prod.tfvars",
+		"show -json tfplan",
+	}, ran)
+	assert.Equal(t, []string{
+		`data.aws_vpc.main.id is "arn:aws:ec2:eu-west-1:123456789012:vpc/vpc-123", but output vpc_id in the producer's state is "vpc-123"`,
+		"data.random_password.main.result differs from output password in the producer's state (sensitive values redacted)",
+		"output zone is unknown after plan",
+		`data.aws_subnet.main.id is "subnet-2", but output subnet_ids in the producer's state is ["subnet-1"]`,
+		`output cidr is "10.0.0.0/16", but output cidr in the producer's state is "10.0.0.0/8"`,
+	}, problems)
+
+	ran = nil
+	runner.failing = map[string]bool{"validate": true}
+	problems, err = verifyInterface(context.Background(), runner, settings, "/producer", module, files, nil, stateOutputs, true)
+	assert.Nil(t, err)
+	assert.Len(t, ran, 2)
+	assert.True(t, strings.HasPrefix(problems[0], "validate failed: Error: Invalid reference"))
+
+	// plan cannot read the data sources without the lookup values that were not in state.
+	ran = nil
+	runner.failing = nil
+	settings.Env = map[string]string{"TF_VAR_aws_vpc_main_id": "vpc-123"}
+	problems, err = verifyInterface(context.Background(), runner, settings, "/producer", module, files, []string{"aws_vpc_main_id", "aws_subnet_main_id"}, stateOutputs, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"init -backend=false -input=false -no-color", "validate -no-color"}, ran)
+	assert.Equal(t, []string{"plan skipped: lookup values that are not in the producer's state are read from the required variables aws_subnet_main_id, set them with TF_VAR_<name> to plan"}, problems)
+
+	ran = nil
+	t.Setenv("TF_VAR_aws_subnet_main_id", "subnet-1")
+	_, err = verifyInterface(context.Background(), runner, settings, "/producer", module, files, []string{"aws_vpc_main_id", "aws_subnet_main_id"}, stateOutputs, true)
+	assert.Nil(t, err)
+	assert.Len(t, ran, 4)
+}